@@ -0,0 +1,302 @@
+package inkdb
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// pulls a number out of a (decoded) value, for the built in aggregations. The name is what their partial results are
+// cached under, so two extractors with the same name need to give the same numbers.
+type NumericExtractor interface {
+	Name() string
+	Number(value any) (float64, error)
+}
+
+// a NumericExtractor made from a function.
+type numberFunc struct {
+	name   string
+	number func(value any) (float64, error)
+}
+
+func (f numberFunc) Name() string                      { return f.name }
+func (f numberFunc) Number(value any) (float64, error) { return f.number(value) }
+
+// makes a NumericExtractor, cached under name, out of number.
+func NewNumericExtractor(name string, number func(value any) (float64, error)) NumericExtractor {
+	return numberFunc{name: name, number: number}
+}
+
+// everything the built in aggregations need to know about a range of numbers.
+type NumericSummary struct {
+	Count int
+	Sum   float64
+	Min   float64
+	Max   float64
+}
+
+// the mean of the numbers. NaN if there weren't any.
+func (s NumericSummary) Avg() float64 {
+	if s.Count == 0 {
+		return math.NaN()
+	}
+	return s.Sum / float64(s.Count)
+}
+
+func mergeSummaries(a, b NumericSummary) NumericSummary {
+	return NumericSummary{
+		Count: a.Count + b.Count,
+		Sum:   a.Sum + b.Sum,
+		Min:   math.Min(a.Min, b.Min),
+		Max:   math.Max(a.Max, b.Max),
+	}
+}
+
+// maps every record from <from> to <to> with mapFn, then folds them together with reduceFn, in key order. Each splotch
+// is worked out on its own, up to WithScanParallelism at once, and then the splotches' results are reduced together, so
+// reduceFn has to be fine with being handed partial results. As splotches are worked out side by side, mapFn and
+// reduceFn are called from several goroutines at once, so they need to be safe for concurrent use (say, not both
+// adding to the same map). Returns ErrNotFound if there's nothing in the range.
+func Aggregate[T any](ink *InkDB, inksack string, from, to SplotchKey, mapFn func(key SplotchKey, value any) (T, error), reduceFn func(a, b T) T) (T, error) {
+	return AggregateCtx(context.Background(), ink, inksack, from, to, mapFn, reduceFn)
+}
+
+// the same as Aggregate, but gives up once ctx is done, returning ctx.Err().
+func AggregateCtx[T any](ctx context.Context, ink *InkDB, inksack string, from, to SplotchKey, mapFn func(key SplotchKey, value any) (T, error), reduceFn func(a, b T) T) (T, error) {
+	var none T
+	sack, format, err := ink.getSackAndFormat(inksack)
+	if err != nil {
+		return none, err
+	}
+	//there's no telling if two calls were given the same functions, so nothing gets cached.
+	result, err := aggregate(ctx, sack, from, to, ink.parallelism(), "", func(item storedItem) (T, error) {
+		value, err := format.decode(item.Value)
+		if err != nil {
+			return none, err
+		}
+		return mapFn(item.Key, value)
+	}, reduceFn)
+	if err == ErrNotFound {
+		err = fmt.Errorf("%w between %v and %v", ErrNotFound, from.Uint64(), to.Uint64())
+	}
+	return result, err
+}
+
+// how many records there are from <from> to <to>. Nothing needs decoding to count it.
+func Count(ink *InkDB, inksack string, from, to SplotchKey) (int, error) {
+	sack, err := ink.getSack(inksack)
+	if err != nil {
+		return 0, err
+	}
+	count, err := aggregate(context.Background(), sack, from, to, ink.parallelism(), "count", func(storedItem) (int, error) {
+		return 1, nil
+	}, func(a, b int) int {
+		return a + b
+	})
+	if err != nil && err != ErrNotFound {
+		return 0, err
+	}
+	return count, nil
+}
+
+// sums up field over the records from <from> to <to>.
+func Sum(ink *InkDB, inksack string, from, to SplotchKey, field NumericExtractor) (float64, error) {
+	summary, err := Summarize(ink, inksack, from, to, field)
+	return summary.Sum, err
+}
+
+// the smallest field from <from> to <to>. Returns ErrNotFound if there's nothing in the range.
+func Min(ink *InkDB, inksack string, from, to SplotchKey, field NumericExtractor) (float64, error) {
+	summary, err := Summarize(ink, inksack, from, to, field)
+	if err == nil && summary.Count == 0 {
+		err = fmt.Errorf("%w between %v and %v", ErrNotFound, from.Uint64(), to.Uint64())
+	}
+	return summary.Min, err
+}
+
+// the largest field from <from> to <to>. Returns ErrNotFound if there's nothing in the range.
+func Max(ink *InkDB, inksack string, from, to SplotchKey, field NumericExtractor) (float64, error) {
+	summary, err := Summarize(ink, inksack, from, to, field)
+	if err == nil && summary.Count == 0 {
+		err = fmt.Errorf("%w between %v and %v", ErrNotFound, from.Uint64(), to.Uint64())
+	}
+	return summary.Max, err
+}
+
+// the mean of field from <from> to <to>. Returns ErrNotFound if there's nothing in the range.
+func Avg(ink *InkDB, inksack string, from, to SplotchKey, field NumericExtractor) (float64, error) {
+	summary, err := Summarize(ink, inksack, from, to, field)
+	if err == nil && summary.Count == 0 {
+		err = fmt.Errorf("%w between %v and %v", ErrNotFound, from.Uint64(), to.Uint64())
+	}
+	return summary.Avg(), err
+}
+
+// the count, sum, smallest and largest of field from <from> to <to>, all in one go. An empty range gives a Count of 0.
+// Closed splotches that are wholly inside the range have their summaries cached, so asking again only reads the rest.
+func Summarize(ink *InkDB, inksack string, from, to SplotchKey, field NumericExtractor) (NumericSummary, error) {
+	sack, format, err := ink.getSackAndFormat(inksack)
+	if err != nil {
+		return NumericSummary{}, err
+	}
+	summary, err := aggregate(context.Background(), sack, from, to, ink.parallelism(), "summary:"+field.Name(), func(item storedItem) (NumericSummary, error) {
+		value, err := format.decode(item.Value)
+		if err != nil {
+			return NumericSummary{}, err
+		}
+		number, err := field.Number(value)
+		if err != nil {
+			return NumericSummary{}, err
+		}
+		return NumericSummary{Count: 1, Sum: number, Min: number, Max: number}, nil
+	}, mergeSummaries)
+	if err == ErrNotFound {
+		return NumericSummary{}, nil
+	}
+	return summary, err
+}
+
+// a splotch's part of an aggregation.
+type partial[T any] struct {
+	value T
+	found bool //if the splotch had anything in the range at all
+}
+
+// works out each splotch's partial result on up to workers goroutines at once, then reduces them together. Partials of
+// closed splotches wholly inside the range are cached under cacheAs, unless it's empty.
+func aggregate[T any](ctx context.Context, sack *inkSack, from, to SplotchKey, workers int, cacheAs string, mapFn func(item storedItem) (T, error), reduceFn func(a, b T) T) (T, error) {
+	var none T
+	if err := sack.lock(); err != nil {
+		return none, err
+	}
+	generation := sack.partials.generation
+	inRange := []*inkSplotch{}
+	indexes := []int{}
+	for i, splotch := range sack.inkSplotches {
+		if splotch.headings.LinesStored == 0 || from.GreaterThan(splotch.headings.LargestKey) || to.LessThan(splotch.smallestKey) {
+			continue
+		}
+		inRange = append(inRange, splotch)
+		indexes = append(indexes, i)
+	}
+	partials := make([]partial[T], len(inRange))
+	cacheable := make([]bool, len(inRange))
+	toRead := []int{}
+	for p, splotch := range inRange {
+		//the last splotch is still being added to, and one only partly in range only has part of its result wanted.
+		cacheable[p] = cacheAs != "" && indexes[p] != len(sack.inkSplotches)-1 &&
+			splotch.smallestKey.GreaterOrEqual(from) && splotch.headings.LargestKey.LessOrEqual(to)
+		if cacheable[p] {
+			if cached, found := sack.partials.get(splotch, cacheAs).(partial[T]); found {
+				partials[p] = cached
+				continue
+			}
+		}
+		toRead = append(toRead, p)
+	}
+	items := make([][]storedItem, len(inRange))
+	err := parallelEach(ctx, workers, len(toRead), func(r int) error {
+		p := toRead[r]
+		found, err := sack.splotchGetAll(indexes[p], from, to)
+		if err != nil && err != ErrSplotchRangeExceeded {
+			return err
+		}
+		items[p] = sack.hideTombstoned(found)
+		return nil
+	})
+	sack.mu.Unlock()
+	if err != nil {
+		return none, err
+	}
+
+	//the sack is unlocked by now, so appends carry on while the records are worked through.
+	err = parallelEach(ctx, workers, len(toRead), func(r int) error {
+		p := toRead[r]
+		for _, item := range items[p] {
+			mapped, err := mapFn(item)
+			if err != nil {
+				return err
+			}
+			if partials[p].found {
+				partials[p].value = reduceFn(partials[p].value, mapped)
+			} else {
+				partials[p] = partial[T]{value: mapped, found: true}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return none, err
+	}
+
+	if cacheAs != "" && len(toRead) != 0 {
+		if err := sack.lock(); err == nil {
+			for _, p := range toRead {
+				if cacheable[p] {
+					sack.partials.put(generation, sack.inkSplotches, inRange[p], cacheAs, partials[p])
+				}
+			}
+			sack.mu.Unlock()
+		}
+	}
+
+	result := partial[T]{}
+	for _, part := range partials {
+		if !part.found {
+			continue
+		}
+		if result.found {
+			result.value = reduceFn(result.value, part.value)
+		} else {
+			result = part
+		}
+	}
+	if !result.found {
+		return none, ErrNotFound
+	}
+	return result.value, nil
+}
+
+// partial aggregations of closed splotches, by splotch and then by what they were cached under. Closed splotches never
+// change, so they're good until something hides one of their records. Guarded by the sack's lock.
+type partialCache struct {
+	generation int //bumped every time the cache is forgotten, so partials worked out before then aren't put back
+	partials   map[*inkSplotch]map[string]any
+}
+
+func (c *partialCache) get(splotch *inkSplotch, name string) any {
+	return c.partials[splotch][name]
+}
+
+// caches value, as long as nothing has been forgotten since generation. Splotches no longer in live (compacted or
+// removed since) are let go of while we're at it.
+func (c *partialCache) put(generation int, live []*inkSplotch, splotch *inkSplotch, name string, value any) {
+	if generation != c.generation {
+		return
+	}
+	if c.partials == nil {
+		c.partials = map[*inkSplotch]map[string]any{}
+	}
+	stillLive := map[*inkSplotch]bool{}
+	for _, s := range live {
+		stillLive[s] = true
+	}
+	if !stillLive[splotch] {
+		return
+	}
+	for s := range c.partials {
+		if !stillLive[s] {
+			delete(c.partials, s)
+		}
+	}
+	if c.partials[splotch] == nil {
+		c.partials[splotch] = map[string]any{}
+	}
+	c.partials[splotch][name] = value
+}
+
+// drops every cached partial.
+func (c *partialCache) forget() {
+	c.generation++
+	c.partials = nil
+}
@@ -0,0 +1,155 @@
+package inkdb
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregate(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder, WithScanParallelism(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink.Close()
+	if err := ink.NewTable("table", &testableObject{}, WithRollover(RolloverPolicy{MaxRows: 3})); err != nil {
+		t.Fatal(err)
+	}
+	//IntVals 1 to 10, under keys 1 to 10, in splotches of three.
+	for i := 1; i <= 10; i++ {
+		if err := ink.Append("table", testableObject{"object", i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	everything, all := KeyFromUint64(0), MaxSplotchKey
+
+	//joins the IntVals together in key order, which only works out if the splotches are put back in order.
+	joined, err := Aggregate(ink, "table", everything, all, func(key SplotchKey, value any) ([]int, error) {
+		return []int{value.(*testableObject).IntVal}, nil
+	}, func(a, b []int) []int {
+		return append(append([]int{}, a...), b...)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, joined)
+	_, err = Aggregate(ink, "table", KeyFromUint64(100), all, func(key SplotchKey, value any) (int, error) {
+		return 1, nil
+	}, func(a, b int) int { return a + b })
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	calls := int64(0)
+	intVal := NewNumericExtractor("intVal", func(value any) (float64, error) {
+		atomic.AddInt64(&calls, 1)
+		return float64(value.(*testableObject).IntVal), nil
+	})
+	summary, err := Summarize(ink, "table", KeyFromUint64(2), KeyFromUint64(9), intVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, NumericSummary{Count: 8, Sum: 44, Min: 2, Max: 9}, summary)
+	assert.Equal(t, 5.5, summary.Avg())
+
+	//[4 5 6] and [7 8 9] were wholly in range and closed, so they're cached. Only 2 and 3 need reading again.
+	calls = 0
+	sum, err := Sum(ink, "table", KeyFromUint64(2), KeyFromUint64(9), intVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 44.0, sum)
+	assert.Equal(t, int64(2), calls)
+
+	count, err := Count(ink, "table", everything, all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 10, count)
+	smallest, _ := Min(ink, "table", everything, all, intVal)
+	assert.Equal(t, 1.0, smallest)
+	largest, _ := Max(ink, "table", everything, all, intVal)
+	assert.Equal(t, 10.0, largest)
+	avg, _ := Avg(ink, "table", everything, all, intVal)
+	assert.Equal(t, 5.5, avg)
+
+	//a tombstone changes what a closed splotch holds, so nothing cached can be trusted.
+	if err := ink.Tombstone("table", KeyFromUint64(5)); err != nil {
+		t.Fatal(err)
+	}
+	sum, _ = Sum(ink, "table", KeyFromUint64(2), KeyFromUint64(9), intVal)
+	assert.Equal(t, 39.0, sum)
+	count, _ = Count(ink, "table", everything, all)
+	assert.Equal(t, 9, count)
+
+	//nothing in range.
+	count, err = Count(ink, "table", KeyFromUint64(100), all)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	sum, err = Sum(ink, "table", KeyFromUint64(100), all, intVal)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, sum)
+	_, err = Avg(ink, "table", KeyFromUint64(100), all, intVal)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = Count(ink, "missing", everything, all)
+	assert.ErrorIs(t, err, ErrNoTable)
+}
+
+func TestAggregateReadOnly(t *testing.T) {
+	folder := getInkTestFile()
+	writer, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	if err := writer.NewTable("table", &testableObject{}, WithRollover(RolloverPolicy{MaxRows: 3})); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 10; i++ {
+		if err := writer.Append("table", testableObject{"object", i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Tombstone("table", KeyFromUint64(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := OpenReadOnly(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if err := reader.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	calls := int64(0)
+	intVal := NewNumericExtractor("intVal", func(value any) (float64, error) {
+		atomic.AddInt64(&calls, 1)
+		return float64(value.(*testableObject).IntVal), nil
+	})
+	sum, err := Sum(reader, "table", KeyFromUint64(0), MaxSplotchKey, intVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 54.0, sum)
+
+	//every read catches up with the writer, but the tombstone log hasn't changed, so the cache still holds. Only the
+	//last splotch, [10], is read again.
+	calls = 0
+	sum, _ = Sum(reader, "table", KeyFromUint64(0), MaxSplotchKey, intVal)
+	assert.Equal(t, 54.0, sum)
+	assert.Equal(t, int64(1), calls)
+
+	//once the writer tombstones something else, it has.
+	if err := writer.Tombstone("table", KeyFromUint64(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	sum, _ = Sum(reader, "table", KeyFromUint64(0), MaxSplotchKey, intVal)
+	assert.Equal(t, 52.0, sum)
+}
@@ -0,0 +1,332 @@
+package inkdb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// archived splotch files are gzipped, and get this on the end of their name.
+const archiveSuffix = ".gz"
+
+// what the manifest remembers about an archived splotch, so it can be found again without unpacking the archive.
+type archiveStub struct {
+	Dir         string //the archive directory it's in. Empty while it isn't archived.
+	Headings    fileHeadings
+	SmallestKey SplotchKey
+}
+
+// if the entry's splotch has been archived.
+func (entry manifestEntry) archived() bool {
+	return entry.Archive.Dir != ""
+}
+
+// a splotch file, opened for reading.
+type splotchFile interface {
+	io.ReaderAt
+	io.Closer
+}
+
+type unpackedFile struct {
+	*bytes.Reader
+}
+
+func (unpackedFile) Close() error { return nil }
+
+// opens a splotch file to read from. Archived ones are compressed, so they get unpacked into memory first.
+func openSplotchFile(location string) (splotchFile, error) {
+	f, err := os.Open(location)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(location, archiveSuffix) {
+		return f, nil
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return unpackedFile{bytes.NewReader(data)}, nil
+}
+
+// moves every closed splotch holding only keys before before into archiveDir, compressed. They stay in the manifest as
+// stubs, so reads still find them (only more slowly), but they no longer take up room on the table's own directories.
+// a table with mirrors keeps a copy of each archive in every mirror too, in place of the mirrored splotch.
+// like Compact, reads and appends carry on while the splotches are being compressed. Returns how many were archived.
+func (ink *InkDB) Archive(table string, before SplotchKey, archiveDir string) (int, error) {
+	if err := ink.checkWritable(); err != nil {
+		return 0, err
+	}
+	sack, err := ink.getSack(table)
+	if err != nil {
+		return 0, err
+	}
+	//nothing else gets to rewrite files while this is going on. The last splotch, the only one appends touch, is left alone.
+	ink.commitMu.Lock()
+	defer ink.commitMu.Unlock()
+	if err := sack.lock(); err != nil {
+		return 0, err
+	}
+	//only what's on the disc can be archived.
+	err = sack.Commit()
+	var jobs []archiveJob
+	if err == nil {
+		jobs, err = sack.planArchive(before, archiveDir)
+	}
+	sack.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	archived, err := sack.archive(jobs)
+	if archived != 0 {
+		ink.logger.Info("archived splotches", zap.String("table", table), zap.Int("splotches", archived), zap.String("archive", archiveDir))
+	}
+	return archived, err
+}
+
+// brings every archived splotch with keys between from and to back out of its archive, to where it was before.
+// returns how many splotches were brought back.
+func (ink *InkDB) Unarchive(table string, from, to SplotchKey) (int, error) {
+	if err := ink.checkWritable(); err != nil {
+		return 0, err
+	}
+	sack, err := ink.getSack(table)
+	if err != nil {
+		return 0, err
+	}
+	ink.commitMu.Lock()
+	defer ink.commitMu.Unlock()
+	if err := sack.lock(); err != nil {
+		return 0, err
+	}
+	defer sack.mu.Unlock()
+	restored, err := sack.unarchive(from, to)
+	if restored != 0 {
+		ink.logger.Info("unarchived splotches", zap.String("table", table), zap.Int("splotches", restored))
+	}
+	return restored, err
+}
+
+// one splotch to archive, and everything needed to write its archive without holding the sack's lock.
+type archiveJob struct {
+	index    int           //where it is in the sack. Only splotches on the end get added while it's unlocked, so this holds
+	entry    manifestEntry //as it is now
+	stubbed  manifestEntry //as it will be, once archived
+	from     string        //the copy to compress
+	copies   []string      //where the archive gets written. The first is the main one, and the rest are in the mirrors
+	fileMode os.FileMode
+}
+
+// works out which splotches get archived, and makes sure there's somewhere to put them. The sack's lock must be held.
+func (is *inkSack) planArchive(before SplotchKey, archiveDir string) ([]archiveJob, error) {
+	if !containsString(is.metadata.ArchiveDirs, archiveDir) {
+		//remembered with the table, so renaming or dropping it takes care of the archive as well.
+		is.metadata.ArchiveDirs = append(is.metadata.ArchiveDirs, archiveDir)
+		if err := is.saveMetadata(); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(is.splotchFolder(archiveDir), is.metadata.dirMode()); err != nil {
+		return nil, err
+	}
+	jobs := []archiveJob{}
+	//the last splotch is still being filled, so it never goes.
+	for i := 0; i < len(is.inkSplotches)-1; i++ {
+		entry := is.manifest.Splotches[i]
+		splotch := is.inkSplotches[i]
+		if entry.archived() || splotch.headings.LinesStored == 0 || splotch.headings.LargestKey.GreaterOrEqual(before) {
+			continue
+		}
+		stubbed := entry
+		stubbed.Checksum, stubbed.Summed = 0, false
+		stubbed.Archive = archiveStub{Dir: archiveDir, Headings: splotch.headings, SmallestKey: splotch.smallestKey}
+		from, _ := is.healthyCopy(entry)
+		jobs = append(jobs, archiveJob{
+			index:    i,
+			entry:    entry,
+			stubbed:  stubbed,
+			from:     from,
+			copies:   is.entryCopies(stubbed),
+			fileMode: is.metadata.fileMode(),
+		})
+	}
+	return jobs, nil
+}
+
+// writes every job's archive (and its mirrors) without the sack's lock, then takes it just long enough to point the
+// manifest at them. The old copies are only removed after that. If anything fails, nothing is archived.
+func (is *inkSack) archive(jobs []archiveJob) (int, error) {
+	if len(jobs) == 0 {
+		return 0, nil
+	}
+	for n := range jobs {
+		if err := jobs[n].write(); err != nil {
+			discardArchives(jobs[:n+1])
+			return 0, err
+		}
+	}
+
+	if err := is.lock(); err != nil {
+		discardArchives(jobs)
+		return 0, err
+	}
+	for _, job := range jobs {
+		is.manifest.Splotches[job.index] = job.stubbed
+	}
+	if err := is.saveManifest(); err != nil {
+		for _, job := range jobs {
+			is.manifest.Splotches[job.index] = job.entry
+		}
+		is.mu.Unlock()
+		discardArchives(jobs)
+		return 0, err
+	}
+	old := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		splotch := is.inkSplotches[job.index]
+		splotch.fileLocation = job.copies[0]
+		splotch.readLocation = ""
+		splotch.storedItems = nil
+		splotch.hasFullyLoaded = false
+		splotch.checksummed = false
+		if job.stubbed.hasChecksum() {
+			splotch.setChecksum(job.stubbed.Checksum)
+		}
+		old = append(old, is.entryCopies(job.entry)...)
+	}
+	//tiering and retention skip archived splotches, so nothing else will be reading what's about to go.
+	is.mu.Unlock()
+
+	for _, location := range old {
+		if err := os.Remove(location); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return len(jobs), err
+		}
+	}
+	return len(jobs), nil
+}
+
+// compresses the splotch into its archive, and copies that out to the mirrors. With mirrors, the archive's checksum
+// is kept, so the copies can be checked against each other.
+func (job *archiveJob) write() error {
+	if err := compressFile(job.from, job.copies[0], job.fileMode); err != nil {
+		return err
+	}
+	if len(job.copies) == 1 {
+		return nil
+	}
+	for _, mirror := range job.copies[1:] {
+		if err := copyFile(job.copies[0], mirror, job.fileMode); err != nil {
+			return err
+		}
+	}
+	sum, err := fileChecksum(job.copies[0])
+	if err != nil {
+		return err
+	}
+	job.stubbed.Checksum, job.stubbed.Summed = sum, true
+	return nil
+}
+
+// removes every archive written for jobs that never made it into the manifest.
+func discardArchives(jobs []archiveJob) {
+	for _, job := range jobs {
+		for _, location := range job.copies {
+			os.Remove(location)
+		}
+	}
+}
+
+func (is *inkSack) unarchive(from, to SplotchKey) (int, error) {
+	restored := 0
+	for i, entry := range is.manifest.Splotches {
+		splotch := is.inkSplotches[i]
+		if !entry.archived() || from.GreaterThan(splotch.headings.LargestKey) || to.LessThan(splotch.smallestKey) {
+			continue
+		}
+		unstubbed := entry
+		unstubbed.Archive = archiveStub{}
+		unstubbed.Checksum, unstubbed.Summed = 0, false
+		from, _ := is.healthyCopy(entry)
+		if err := decompressFile(from, is.entryLocation(unstubbed), is.metadata.fileMode()); err != nil {
+			return restored, err
+		}
+		is.manifest.Splotches[i] = unstubbed
+		if err := is.writeMirrors([]int{i}); err != nil {
+			is.manifest.Splotches[i] = entry
+			return restored, err
+		}
+		if err := is.saveManifest(); err != nil {
+			is.manifest.Splotches[i] = entry
+			return restored, err
+		}
+		splotch.fileLocation = is.entryLocation(unstubbed)
+		splotch.readLocation = ""
+		for _, location := range is.entryCopies(entry) {
+			if err := os.Remove(location); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return restored, err
+			}
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// a splotch that's been archived, made up from its stub. Nothing is read from the archive until it's needed.
+func (is *inkSack) stubSplotch(entry manifestEntry) *inkSplotch {
+	splotch := &inkSplotch{
+		fileLocation: is.entryLocation(entry),
+		smallestKey:  entry.Archive.SmallestKey,
+		headings:     entry.Archive.Headings,
+		rollover:     is.metadata.Rollover,
+		fileMode:     is.metadata.fileMode(),
+		metrics:      is.metrics,
+		logger:       is.logger.With(zap.String("splotch", entry.Name)),
+		readOnly:     is.readOnly,
+	}
+	if entry.hasChecksum() {
+		splotch.setChecksum(entry.Checksum)
+	}
+	return splotch
+}
+
+// writes a gzipped copy of the file at from to to, making sure it's on the disc before returning.
+func compressFile(from, to string, mode os.FileMode) error {
+	return transformFile(from, to, mode, func(out io.Writer, in io.Reader) error {
+		w := gzip.NewWriter(out)
+		if _, err := io.Copy(w, in); err != nil {
+			return err
+		}
+		return w.Close()
+	})
+}
+
+// unpacks the gzipped file at from to to, making sure it's on the disc before returning.
+func decompressFile(from, to string, mode os.FileMode) error {
+	return transformFile(from, to, mode, func(out io.Writer, in io.Reader) error {
+		r, err := gzip.NewReader(in)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, r)
+		return err
+	})
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package inkdb
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkDBArchive(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archiveDir := path.Join(folder, "archive")
+	if err := ink.NewTable("table", []byte{}, WithCodec(rawCodec{}), WithRollover(RolloverPolicy{MaxRows: 2})); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := ink.Append("table", []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkValues := func(ink *InkDB) {
+		values, keys, err := ink.Get("table", KeyFromUint64(0), KeyFromUint64(100))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, keys, 10)
+		for i, value := range values {
+			assert.Equal(t, []byte{byte(i)}, value)
+		}
+	}
+
+	archived, err := ink.Archive("table", KeyFromUint64(5), archiveDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//keys 1 to 4, as the third splotch has key 5 in it.
+	assert.Equal(t, 2, archived)
+	files, _ := os.ReadDir(path.Join(archiveDir, "table", "splotches"))
+	assert.Len(t, files, 2)
+	files, _ = os.ReadDir(path.Join(folder, "inksacks", "table", "splotches"))
+	assert.Len(t, files, 3)
+	checkValues(ink)
+
+	//backups hold the archived splotches unpacked, so they work without the archive.
+	snapshot := path.Join(folder, "archiveSnapshot")
+	if err := ink.Snapshot(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fromSnapshot, err := NewInkDB(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fromSnapshot.NewTable("table", []byte{}); err != nil {
+		t.Fatal(err)
+	}
+	checkValues(fromSnapshot)
+	fromSnapshot.Close()
+
+	//after a restart, the stubs are enough to find them again.
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink2.Close()
+	if err := ink2.NewTable("table", []byte{}); err != nil {
+		t.Fatal(err)
+	}
+	stats, _ := ink2.Stats("table")
+	assert.Equal(t, 10, stats.Records)
+	assert.Equal(t, 0, stats.LoadedSplotches)
+	checkValues(ink2)
+
+	restored, err := ink2.Unarchive("table", KeyFromUint64(0), KeyFromUint64(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, restored)
+	files, _ = os.ReadDir(path.Join(archiveDir, "table", "splotches"))
+	assert.Empty(t, files)
+	files, _ = os.ReadDir(path.Join(folder, "inksacks", "table", "splotches"))
+	assert.Len(t, files, 5)
+	checkValues(ink2)
+
+	_, err = ink2.Archive("missing", KeyFromUint64(5), archiveDir)
+	assert.ErrorIs(t, err, ErrNoTable)
+}
+
+func TestInkDBArchiveMirrors(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink.Close()
+	archiveDir, mirror := path.Join(folder, "archive"), path.Join(folder, "mirror")
+	if err := ink.NewTable("table", []byte{}, WithCodec(rawCodec{}), WithRollover(RolloverPolicy{MaxRows: 2}), WithMirrors(mirror)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		if err := ink.Append("table", []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkValues := func() {
+		values, _, err := ink.Get("table", KeyFromUint64(0), KeyFromUint64(100))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assert.Len(t, values, 6) {
+			for i, value := range values {
+				assert.Equal(t, []byte{byte(i)}, value)
+			}
+		}
+	}
+	archived, err := ink.Archive("table", KeyFromUint64(5), archiveDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, archived)
+	//the mirror keeps a copy of each archive in place of the splotch.
+	mirrored, _ := os.ReadDir(path.Join(mirror, "table", "splotches"))
+	names := []string{}
+	for _, file := range mirrored {
+		names = append(names, file.Name())
+	}
+	assert.Equal(t, []string{"s0x00000000.txt.gz", "s0x00000001.txt.gz", "s0x00000002.txt"}, names)
+
+	//losing the archive itself isn't the end of it.
+	sack := ink.inkSacks["table"]
+	sack.inkSplotches[0].storedItems = nil
+	sack.inkSplotches[0].hasFullyLoaded = false
+	if err := os.Remove(sack.entryLocation(sack.manifest.Splotches[0])); err != nil {
+		t.Fatal(err)
+	}
+	checkValues()
+	report, err := ink.Scrub("table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{sack.entryLocation(sack.manifest.Splotches[0])}, report.Repaired)
+
+	restored, err := ink.Unarchive("table", KeyFromUint64(0), KeyFromUint64(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, restored)
+	mirrored, _ = os.ReadDir(path.Join(mirror, "table", "splotches"))
+	assert.Len(t, mirrored, 3)
+	for _, file := range mirrored {
+		assert.NotContains(t, file.Name(), archiveSuffix)
+	}
+	checkValues()
+}
+
+func TestInkDBArchiveTombstones(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink.Close()
+	if err := ink.NewTable("table", []byte{}, WithCodec(rawCodec{}), WithRollover(RolloverPolicy{MaxRows: 2})); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := ink.Append("table", []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ink.Tombstone("table", KeyFromUint64(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ink.Archive("table", KeyFromUint64(5), path.Join(folder, "archive")); err != nil {
+		t.Fatal(err)
+	}
+
+	//compact leaves archived splotches alone, so the record (and its tombstone) stays put.
+	report, err := ink.Compact("table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, report.RecordsDropped)
+	stats, err := ink.Stats("table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, stats.Tombstones)
+	values, _, err := ink.Get("table", SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, values, 9)
+
+	//once it's back, the next compact drops it like any other.
+	if _, err := ink.Unarchive("table", SplotchKey{}, MaxSplotchKey); err != nil {
+		t.Fatal(err)
+	}
+	report, err = ink.Compact("table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, report.RecordsDropped)
+	stats, err = ink.Stats("table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, stats.Tombstones)
+}
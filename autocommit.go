@@ -0,0 +1,169 @@
+package inkdb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// when the background flusher commits. Whichever limit is hit first triggers it, and any left at 0 are ignored.
+type AutoCommitPolicy struct {
+	Records  int           //commit once this many records are waiting
+	Bytes    int64         //commit once this many bytes of values are waiting
+	Interval time.Duration //commit at least this often, if anything is waiting
+	OnError  func(error)   //called whenever a background commit fails
+}
+
+// commits in the background, following policy. Flush and Close wait for it to catch up.
+func WithAutoCommit(policy AutoCommitPolicy) DBOption {
+	return func(ink *InkDB) {
+		ink.flusher = &flusher{policy: policy}
+	}
+}
+
+// runs the background commits. Every commit covers every flush that was asked for before it started,
+// so any number of callers waiting on Flush at once only cost one commit between them.
+type flusher struct {
+	policy AutoCommitPolicy
+
+	mu             sync.Mutex
+	cond           *sync.Cond
+	pendingRecords int
+	pendingBytes   int64
+	requested      uint64 //how many flushes have been asked for
+	completed      uint64 //how many of those have been covered by a finished commit
+	lastErr        error  //what the last commit returned
+	stopped        bool   //set once close has been called
+	closeErr       error  //what the final commit returned
+	kick           chan struct{}
+	stop           chan struct{}
+	done           chan struct{}
+}
+
+// starts the flusher running for ink.
+func (f *flusher) start(ink *InkDB) {
+	f.cond = sync.NewCond(&f.mu)
+	f.kick = make(chan struct{}, 1)
+	f.stop = make(chan struct{})
+	f.done = make(chan struct{})
+	go f.run(ink)
+}
+
+func (f *flusher) run(ink *InkDB) {
+	defer close(f.done)
+	var tick <-chan time.Time
+	if f.policy.Interval > 0 {
+		ticker := time.NewTicker(f.policy.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-f.kick:
+		case <-tick:
+		case <-f.stop:
+			//one last commit, so nothing waiting is left behind.
+			f.closeErr = f.commit(ink)
+			return
+		}
+		f.commit(ink)
+	}
+}
+
+// commits everything waiting, and lets anyone who asked for it know it's done.
+func (f *flusher) commit(ink *InkDB) error {
+	f.mu.Lock()
+	target := f.requested
+	records, bytes := f.pendingRecords, f.pendingBytes
+	idle := target == f.completed && records == 0 && bytes == 0
+	f.mu.Unlock()
+	if idle {
+		return nil
+	}
+	err := ink.commitCtx(context.Background())
+	if err != nil {
+		ink.logger.Error("background commit failed", zap.Error(err))
+		if f.policy.OnError != nil {
+			f.policy.OnError(err)
+		}
+	}
+	f.mu.Lock()
+	if err == nil {
+		//anything appended while the commit ran is still waiting.
+		f.pendingRecords -= records
+		f.pendingBytes -= bytes
+	}
+	f.completed = target
+	f.lastErr = err
+	f.cond.Broadcast()
+	f.mu.Unlock()
+	return err
+}
+
+// counts newly appended records, kicking off a commit if that takes them past a limit.
+func (f *flusher) added(records int, bytes int64) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	f.pendingRecords += records
+	f.pendingBytes += bytes
+	full := (f.policy.Records > 0 && f.pendingRecords >= f.policy.Records) ||
+		(f.policy.Bytes > 0 && f.pendingBytes >= f.policy.Bytes)
+	f.mu.Unlock()
+	if full {
+		f.wake()
+	}
+}
+
+func (f *flusher) wake() {
+	select {
+	case f.kick <- struct{}{}:
+	default:
+		//already due to run.
+	}
+}
+
+// waits for a commit that started after now to finish, and returns what it returned.
+// returns false if the flusher has already stopped, so there's nothing to wait on.
+func (f *flusher) flush() (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stopped {
+		return false, nil
+	}
+	f.requested++
+	waitingFor := f.requested
+	f.wake()
+	for f.completed < waitingFor {
+		f.cond.Wait()
+	}
+	return true, f.lastErr
+}
+
+// does a final commit, and waits for the flusher to stop. Only the first call does anything.
+func (f *flusher) close() error {
+	f.mu.Lock()
+	if f.stopped {
+		f.mu.Unlock()
+		return nil
+	}
+	f.stopped = true
+	f.mu.Unlock()
+	close(f.stop)
+	<-f.done
+	return f.closeErr
+}
+
+// makes sure everything appended so far is on the disc. With auto commit, this joins in with the background
+// commits instead of starting one of its own.
+func (ink *InkDB) Flush() error {
+	if ink.flusher != nil {
+		if flushed, err := ink.flusher.flush(); flushed {
+			return err
+		}
+	}
+	return ink.Commit()
+}
@@ -0,0 +1,147 @@
+package inkdb
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// how many records are still waiting to be committed.
+func uncommitted(t *testing.T, ink *InkDB, table string) int {
+	stats, err := ink.Stats(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats.UncommittedRecords
+}
+
+func TestInkDBAutoCommit(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder, WithAutoCommit(AutoCommitPolicy{Records: 5}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := ink.Append("table", generateTestableObject(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 4, uncommitted(t, ink, "table"))
+	//the fifth takes it over the limit.
+	if err := ink.Append("table", generateTestableObject(4)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool { return uncommitted(t, ink, "table") == 0 }, time.Second, time.Millisecond)
+
+	//close commits whatever's left.
+	if err := ink.Append("table", generateTestableObject(5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, _ := ink2.Stats("table")
+	assert.Equal(t, 6, stats.Records)
+}
+
+func TestInkDBAutoCommitInterval(t *testing.T) {
+	ink, err := NewInkDB(getInkTestFile(), WithAutoCommit(AutoCommitPolicy{Interval: 10 * time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink.Close()
+	if err := ink.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Append("table", generateTestableObject(0)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool { return uncommitted(t, ink, "table") == 0 }, time.Second, time.Millisecond)
+}
+
+func TestInkDBGroupCommit(t *testing.T) {
+	metrics := NewMetrics()
+	ink, err := NewInkDB(getInkTestFile(), WithAutoCommit(AutoCommitPolicy{}), WithMetrics(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink.Close()
+	if err := ink.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Append("table", generateTestableObject(0)); err != nil {
+		t.Fatal(err)
+	}
+	//hold up the first commit, so every flush after it piles up behind it.
+	ink.commitMu.Lock()
+	wg := sync.WaitGroup{}
+	flush := func() {
+		defer wg.Done()
+		assert.NoError(t, ink.Flush())
+	}
+	wg.Add(1)
+	go flush()
+	assert.Eventually(t, func() bool {
+		ink.flusher.mu.Lock()
+		defer ink.flusher.mu.Unlock()
+		return ink.flusher.requested == 1
+	}, time.Second, time.Millisecond)
+	//give the flusher time to start on the held up commit.
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < 9; i++ {
+		wg.Add(1)
+		go flush()
+	}
+	assert.Eventually(t, func() bool {
+		ink.flusher.mu.Lock()
+		defer ink.flusher.mu.Unlock()
+		return ink.flusher.requested == 10
+	}, time.Second, time.Millisecond)
+	ink.commitMu.Unlock()
+	wg.Wait()
+	//the first flush, then (at most) one more for all the rest.
+	assert.LessOrEqual(t, metrics.Counter(commitMetric.total, "table"), 2.0)
+}
+
+func TestInkDBAutoCommitErrors(t *testing.T) {
+	failures := make(chan error, 10)
+	ink, err := NewInkDB(getInkTestFile(), WithAutoCommit(AutoCommitPolicy{
+		Records: 1,
+		OnError: func(err error) { failures <- err },
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	//the splotch is made while there's room, but there's none left by the time it's committed.
+	if err := ink.Append("table", generateTestableObject(0)); err != nil {
+		t.Fatal(err)
+	}
+	ink.inkSacks["table"].mu.Lock()
+	ink.inkSacks["table"].minFreeSpace = 1 << 62
+	ink.inkSacks["table"].mu.Unlock()
+	if err := ink.Append("table", generateTestableObject(1)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-failures:
+		assert.True(t, errors.Is(err, ErrInsufficientSpace))
+	case <-time.After(time.Second):
+		t.Fatal("no error reported")
+	}
+	assert.True(t, errors.Is(ink.Flush(), ErrInsufficientSpace))
+	assert.True(t, errors.Is(ink.Close(), ErrInsufficientSpace))
+}
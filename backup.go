@@ -0,0 +1,308 @@
+package inkdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// the name of the file every backup (and snapshot) keeps its BackupManifest in.
+const backupManifestName = "backup.json"
+
+// what a backup holds, so the next one knows what it can skip.
+type BackupManifest struct {
+	ID     string //when the backup was taken
+	Since  string //the ID of the backup this one was taken on top of. Empty for a full backup.
+	Tables map[string]BackupTable
+}
+
+// one table's part of a backup.
+type BackupTable struct {
+	LargestKey SplotchKey      //the largest committed key at the time
+	Splotches  []BackupSplotch //every splotch the table had, in key order. Including ones left for an earlier backup.
+}
+
+// one splotch's part of a backup.
+type BackupSplotch struct {
+	Name     string
+	Checksum uint32 //crc32 of the whole file
+	Copied   bool   //if this backup has a copy of it. Otherwise an earlier one in the chain does.
+}
+
+// finds the splotch called name, if the table has it.
+func (bt BackupTable) find(name string) (BackupSplotch, bool) {
+	for _, splotch := range bt.Splotches {
+		if splotch.Name == name {
+			return splotch, true
+		}
+	}
+	return BackupSplotch{}, false
+}
+
+// reads the manifest out of a backup folder, or from the manifest file itself.
+func LoadBackupManifest(location string) (BackupManifest, error) {
+	if info, err := os.Stat(location); err == nil && info.IsDir() {
+		location = path.Join(location, backupManifestName)
+	}
+	manifest := BackupManifest{}
+	data, err := os.ReadFile(location)
+	if err != nil {
+		return manifest, err
+	}
+	return manifest, json.Unmarshal(data, &manifest)
+}
+
+// backs up everything committed since the backup described by manifest, into dest. Only splotches that are new or
+// have grown get copied. An empty manifest takes a full backup instead. Either way, dest gets its own manifest for next time.
+func (ink *InkDB) BackupSince(manifest string, dest string) error {
+	var previous *BackupManifest
+	if manifest != "" {
+		loaded, err := LoadBackupManifest(manifest)
+		if err != nil {
+			return err
+		}
+		previous = &loaded
+	}
+	return ink.backup(dest, previous)
+}
+
+// takes a backup into dest, skipping anything the previous backup already has (if there is one).
+func (ink *InkDB) backup(dest string, previous *BackupManifest) error {
+	if err := ink.checkOpen(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup destination %v already exists", dest)
+	}
+	//nothing is written to the splotch files outside of a commit, so holding this freezes every table at the same point.
+	ink.commitMu.Lock()
+	defer ink.commitMu.Unlock()
+
+	//build it off to the side, so a half finished backup never looks like a real one.
+	building := dest + ".tmp"
+	if err := os.RemoveAll(building); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Join(building, "inksacks"), 0777); err != nil {
+		return err
+	}
+	manifest := BackupManifest{
+		ID:     time.Now().UTC().Format(time.RFC3339Nano),
+		Tables: map[string]BackupTable{},
+	}
+	if previous != nil {
+		manifest.Since = previous.ID
+	}
+	for name, sack := range ink.allSacks() {
+		var previousTable *BackupTable
+		if previous != nil {
+			if table, ok := previous.Tables[name]; ok {
+				previousTable = &table
+			}
+		}
+		table, err := sack.backup(path.Join(building, "inksacks", name), previousTable)
+		if err != nil {
+			ink.logger.Error("backup failed", zap.String("table", name), zap.Error(err))
+			os.RemoveAll(building)
+			return err
+		}
+		manifest.Tables[name] = table
+	}
+	if err := writeBackupManifest(building, manifest); err != nil {
+		os.RemoveAll(building)
+		return err
+	}
+	if err := os.Rename(building, dest); err != nil {
+		return err
+	}
+	ink.logger.Info("took backup", zap.String("dest", dest), zap.String("since", manifest.Since))
+	return nil
+}
+
+// saves the manifest into a backup folder.
+func writeBackupManifest(dir string, manifest BackupManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(dir, backupManifestName), data, 0644)
+}
+
+// copies the committed state of the sack into dest, leaving out any splotches previous already has a matching copy of.
+// everything ends up in dest's own splotches folder, whatever data directories and mirrors the sack had.
+// the caller needs to be holding the commit lock.
+func (is *inkSack) backup(dest string, previous *BackupTable) (BackupTable, error) {
+	is.mu.Lock()
+	metadata := is.metadata
+	is.mu.Unlock()
+	//the manifest on disc is the one that matches the last commit. The one in memory might have new splotches in it.
+	source := &inkSack{localFilesLocation: is.localFilesLocation, metadata: metadata}
+	manifest, found, err := source.loadManifest()
+	if err != nil {
+		return BackupTable{}, err
+	}
+	if !found {
+		if manifest, err = source.legacyManifest(); err != nil {
+			return BackupTable{}, err
+		}
+	}
+	table := BackupTable{}
+	if table.LargestKey, err = source.committedLargestKey(manifest); err != nil {
+		return BackupTable{}, err
+	}
+	//if nothing's been added since, there's no need to even read the files. Compact swaps splotches for new ones without
+	//adding anything, so the names all have to match as well.
+	unchanged := previous != nil && previous.LargestKey == table.LargestKey && len(previous.Splotches) == len(manifest.Splotches)
+	for i := 0; unchanged && i < len(manifest.Splotches); i++ {
+		unchanged = previous.Splotches[i].Name == manifest.Splotches[i].Name
+	}
+
+	metadata.DataDirs = nil
+	metadata.Mirrors = nil
+	metadata.Placement = RoundRobin
+	copied := &inkSack{localFilesLocation: dest, metadata: metadata}
+	if err := os.MkdirAll(copied.splotchFolder(""), metadata.dirMode()); err != nil {
+		return BackupTable{}, err
+	}
+	copied.manifest.NextSplotch = manifest.NextSplotch
+	for i, entry := range manifest.Splotches {
+		copiedEntry := manifestEntry{Name: entry.Name}
+		copied.manifest.Splotches = append(copied.manifest.Splotches, copiedEntry)
+		if unchanged {
+			table.Splotches = append(table.Splotches, BackupSplotch{Name: entry.Name, Checksum: previous.Splotches[i].Checksum})
+			continue
+		}
+		from, _ := source.healthyCopy(entry)
+		sum, err := fileChecksum(from)
+		if err != nil {
+			return BackupTable{}, err
+		}
+		splotch := BackupSplotch{Name: entry.Name, Checksum: sum}
+		if previous != nil {
+			if before, ok := previous.find(entry.Name); ok && before.Checksum == sum {
+				//an earlier backup already has this exact file.
+				table.Splotches = append(table.Splotches, splotch)
+				continue
+			}
+		}
+		splotch.Copied = true
+		to := copied.entryLocation(copiedEntry)
+		if entry.archived() {
+			//backups hold every splotch as it normally is, so they can be restored without the archive.
+			if err := decompressFile(from, to, metadata.fileMode()); err != nil {
+				return BackupTable{}, err
+			}
+		} else if i == len(manifest.Splotches)-1 || os.Link(from, to) != nil {
+			//only the last splotch ever gets written to again, so the rest are shared with a hard link where they can be.
+			if err := copyFile(from, to, metadata.fileMode()); err != nil {
+				return BackupTable{}, err
+			}
+		}
+		table.Splotches = append(table.Splotches, splotch)
+	}
+	if err := copied.saveMetadata(); err != nil {
+		return BackupTable{}, err
+	}
+	if err := source.copyTombstones(dest); err != nil {
+		return BackupTable{}, err
+	}
+	return table, copied.saveManifest()
+}
+
+// the largest key committed to the splotches in manifest, read from their headings.
+func (is *inkSack) committedLargestKey(manifest sackManifest) (SplotchKey, error) {
+	for i := len(manifest.Splotches) - 1; i >= 0; i-- {
+		location, _ := is.healthyCopy(manifest.Splotches[i])
+		f, err := openSplotchFile(location)
+		if err != nil {
+			return SplotchKey{}, err
+		}
+		headings := fileHeadings{}
+		_, err = readHeadings(f, &headings)
+		f.Close()
+		if err != nil {
+			return SplotchKey{}, err
+		}
+		if headings.LinesStored != 0 {
+			return headings.LargestKey, nil
+		}
+	}
+	return SplotchKey{}, nil
+}
+
+// rebuilds a database into dest, from a full backup followed by any incremental backups taken on top of it (oldest first).
+func Restore(dest string, backups ...string) error {
+	if len(backups) == 0 {
+		return fmt.Errorf("nothing to restore from")
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("restore destination %v already exists", dest)
+	}
+	manifests := make([]BackupManifest, len(backups))
+	for i, backup := range backups {
+		manifest, err := LoadBackupManifest(backup)
+		if err != nil {
+			return err
+		}
+		//make sure each backup really was taken on top of the one before it.
+		if i == 0 && manifest.Since != "" {
+			return fmt.Errorf("%v is an incremental backup, restoring needs to start from a full one", backup)
+		}
+		if i != 0 && manifest.Since != manifests[i-1].ID {
+			return fmt.Errorf("%v wasn't taken on top of %v", backup, backups[i-1])
+		}
+		manifests[i] = manifest
+	}
+
+	building := dest + ".tmp"
+	if err := os.RemoveAll(building); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Join(building, "inksacks"), 0777); err != nil {
+		return err
+	}
+	latest := len(backups) - 1
+	for name, table := range manifests[latest].Tables {
+		tableDir := path.Join(building, "inksacks", name)
+		if err := os.MkdirAll(path.Join(tableDir, "splotches"), 0777); err != nil {
+			return err
+		}
+		for _, splotch := range table.Splotches {
+			//the newest backup with a matching copy is where it comes from.
+			from := ""
+			for i := latest; i >= 0 && from == ""; i-- {
+				if found, ok := manifests[i].Tables[name].find(splotch.Name); ok && found.Copied && found.Checksum == splotch.Checksum {
+					from = path.Join(backups[i], "inksacks", name, "splotches", splotch.Name)
+				}
+			}
+			if from == "" {
+				os.RemoveAll(building)
+				return fmt.Errorf("no backup has a copy of %v from %v", splotch.Name, name)
+			}
+			if err := copyFile(from, path.Join(tableDir, "splotches", splotch.Name), 0644); err != nil {
+				os.RemoveAll(building)
+				return err
+			}
+		}
+		//the table's settings, manifest and tombstones come from the newest backup.
+		for _, file := range []string{"inkSackData", "manifest", "tombstones"} {
+			from := path.Join(backups[latest], "inksacks", name, file)
+			if _, err := os.Stat(from); err != nil {
+				continue
+			}
+			if err := copyFile(from, path.Join(tableDir, file), 0644); err != nil {
+				os.RemoveAll(building)
+				return err
+			}
+		}
+	}
+	if err := writeBackupManifest(building, manifests[latest]); err != nil {
+		os.RemoveAll(building)
+		return err
+	}
+	return os.Rename(building, dest)
+}
@@ -0,0 +1,157 @@
+package inkdb
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkDBIncrementalBackups(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backups := path.Join(folder, "backups")
+	os.RemoveAll(backups)
+	if err := ink.NewTable("table", &testableObject{}, WithRollover(RolloverPolicy{MaxRows: 10})); err != nil {
+		t.Fatal(err)
+	}
+	added := 0
+	addAndCommit := func(count int) {
+		for i := 0; i < count; i++ {
+			if err := ink.Append("table", generateTestableObject(added)); err != nil {
+				t.Fatal(err)
+			}
+			added++
+		}
+		if err := ink.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	copiedFiles := func(backup string) int {
+		files, _ := os.ReadDir(path.Join(backup, "inksacks", "table", "splotches"))
+		return len(files)
+	}
+
+	addAndCommit(25)
+	full := path.Join(backups, "full")
+	if err := ink.Snapshot(full); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, copiedFiles(full))
+
+	//the last splotch grew, and two more were made. The first two are untouched.
+	addAndCommit(20)
+	second := path.Join(backups, "second")
+	if err := ink.BackupSince(full, second); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, copiedFiles(second))
+
+	//nothing new at all.
+	third := path.Join(backups, "third")
+	if err := ink.BackupSince(path.Join(second, "backup.json"), third); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, copiedFiles(third))
+
+	addAndCommit(1)
+	fourth := path.Join(backups, "fourth")
+	if err := ink.BackupSince(third, fourth); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, copiedFiles(fourth))
+
+	//the chain needs to be in the right order, and start with a full backup.
+	assert.Error(t, Restore(path.Join(backups, "restoreBad"), second, third))
+	assert.Error(t, Restore(path.Join(backups, "restoreBad"), full, third))
+
+	restored := path.Join(backups, "restored")
+	if err := Restore(restored, full, second, third, fourth); err != nil {
+		t.Fatal(err)
+	}
+	restoredDB, err := NewInkDB(restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restoredDB.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	vals, _, err := restoredDB.Get("table", SplotchKey{}, SplotchKey{}.Plus(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Equal(t, added, len(vals)) {
+		for i, val := range vals {
+			assert.Equal(t, generateTestableObject(i), val)
+		}
+	}
+	//and it should carry on like the original would.
+	if err := restoredDB.Append("table", generateTestableObject(added)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 5, len(restoredDB.inkSacks["table"].inkSplotches))
+	assert.Equal(t, SplotchKey{}.Plus(added+1), restoredDB.inkSacks["table"].largestKey)
+}
+
+func TestInkDBBackupAfterCompact(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink.Close()
+	backups := path.Join(folder, "backups")
+	if err := ink.NewTable("table", &testableObject{}, WithRollover(RolloverPolicy{MaxRows: 2})); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := ink.Append("table", generateTestableObject(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ink.Tombstone("table", KeyFromUint64(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	full := path.Join(backups, "full")
+	if err := ink.Snapshot(full); err != nil {
+		t.Fatal(err)
+	}
+	//the same records and the same number of splotches, but not the same splotches.
+	if _, err := ink.Compact("table"); err != nil {
+		t.Fatal(err)
+	}
+	incremental := path.Join(backups, "incremental")
+	if err := ink.BackupSince(full, incremental); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := path.Join(backups, "restored")
+	if err := Restore(restored, full, incremental); err != nil {
+		t.Fatal(err)
+	}
+	restoredDB, err := NewInkDB(restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restoredDB.Close()
+	if err := restoredDB.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	want, wantKeys, err := ink.Get("table", KeyFromUint64(0), MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, gotKeys, err := restoredDB.Get("table", KeyFromUint64(0), MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, wantKeys, gotKeys)
+	assert.Equal(t, want, got)
+}
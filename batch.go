@@ -0,0 +1,245 @@
+package inkdb
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"reflect"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// a codec that can encode a whole batch of values faster than one at a time. Every value it gives back still has to
+// decode on its own, with plain Decode.
+type BatchCodec interface {
+	Codec
+	EncodeBatch(items []any) ([][]byte, error)
+}
+
+// every gob value needs its own copy of the type info, but working that out is most of the cost of encoding.
+// so it's worked out once for each type, and stuck on the front of each value encoded after it.
+func (GobCodec) EncodeBatch(items []any) ([][]byte, error) {
+	out := make([][]byte, len(items))
+	buffer := &bytes.Buffer{}
+	var enc *gob.Encoder
+	var preamble []byte
+	var preambleType reflect.Type
+	for i, item := range items {
+		itemType := reflect.TypeOf(item)
+		if enc == nil || itemType != preambleType {
+			if !gobPreambleSafe(itemType) {
+				//types inside interfaces are only sent the first time they're seen, so this can't share them.
+				data, err := (GobCodec{}).Encode(item)
+				if err != nil {
+					return nil, err
+				}
+				out[i] = data
+				enc = nil
+				continue
+			}
+			//encoding the same value twice gives the type info and the value, then the value alone. The difference is the type info.
+			buffer.Reset()
+			enc = gob.NewEncoder(buffer)
+			if err := enc.Encode(item); err != nil {
+				return nil, err
+			}
+			first := append([]byte{}, buffer.Bytes()...)
+			buffer.Reset()
+			if err := enc.Encode(item); err != nil {
+				return nil, err
+			}
+			preamble = first[:len(first)-buffer.Len()]
+			preambleType = itemType
+			out[i] = first
+			continue
+		}
+		buffer.Reset()
+		if err := enc.Encode(item); err != nil {
+			return nil, err
+		}
+		data := make([]byte, 0, len(preamble)+buffer.Len())
+		out[i] = append(append(data, preamble...), buffer.Bytes()...)
+	}
+	return out, nil
+}
+
+// if every value of type t encodes to the same type info. Anything holding an interface doesn't.
+func gobPreambleSafe(t reflect.Type) bool {
+	return t != nil && !holdsInterface(t, map[reflect.Type]bool{})
+}
+
+func holdsInterface(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return holdsInterface(t.Elem(), seen)
+	case reflect.Map:
+		return holdsInterface(t.Key(), seen) || holdsInterface(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if holdsInterface(t.Field(i).Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// turns a batch of items into the bytes stored for each, using the table's codec and compression.
+func (vf valueFormat) encodeBatch(items []any) ([][]byte, error) {
+	var encoded [][]byte
+	if batchCodec, ok := vf.codec.(BatchCodec); ok {
+		var err error
+		if encoded, err = batchCodec.EncodeBatch(items); err != nil {
+			return nil, err
+		}
+	} else {
+		encoded = make([][]byte, len(items))
+		for i, item := range items {
+			data, err := vf.codec.Encode(item)
+			if err != nil {
+				return nil, err
+			}
+			encoded[i] = data
+		}
+	}
+	for i, data := range encoded {
+		compressed, err := vf.compression.compress(data)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = compressed
+	}
+	return encoded, nil
+}
+
+// appends every item to the table in one go, and returns the keys they were given. The keys are one after another,
+// with nothing else from the table in between. Either every item is added, or none of them are.
+func (ink *InkDB) AppendBatch(table string, items []any) (keys []SplotchKey, err error) {
+	if err := ink.checkWritable(); err != nil {
+		return nil, err
+	}
+	sack, format, err := ink.getSackAndFormat(table)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	defer func() {
+		sack.metrics.record(appendBatchMetric, start, err)
+		if err == nil {
+			sack.metrics.add(appendBatchRecordsMetric, float64(len(keys)))
+		}
+	}()
+	if len(items) == 0 {
+		return []SplotchKey{}, nil
+	}
+	encoded, err := format.encodeBatch(items)
+	if err != nil {
+		return nil, err
+	}
+	total := 0
+	for _, data := range encoded {
+		total += len(data)
+	}
+	release, err := ink.reserveQuota(total)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if err := sack.lock(); err != nil {
+		return nil, err
+	}
+	defer sack.mu.Unlock()
+	if keys, err = sack.appendBatch(encoded, total); err != nil {
+		return nil, err
+	}
+	ink.flusher.added(len(keys), int64(total))
+	return keys, nil
+}
+
+// where a sack was before a batch started, so it can be put back if the batch fails part way.
+type batchMark struct {
+	splotches  int
+	last       *inkSplotch
+	headings   fileHeadings
+	smallest   SplotchKey
+	unsaved    int
+	stored     int
+	largestKey SplotchKey
+	usedBytes  int64
+	changed    bool
+}
+
+// adds already encoded items under a reserved, contiguous range of keys. Rolls over into new splotches as needed.
+func (is *inkSack) appendBatch(encoded [][]byte, total int) ([]SplotchKey, error) {
+	if err := is.checkQuota(total); err != nil {
+		return nil, err
+	}
+	generator, err := lookupKeyGenerator(is.metadata.KeyGenerator)
+	if err != nil {
+		return nil, err
+	}
+	mark := is.markBatch()
+	//the generator picks the first key, the rest follow straight on from it.
+	first := generator.NextKey(is.largestKey)
+	keys := make([]SplotchKey, len(encoded))
+	for i, data := range encoded {
+		if len(is.inkSplotches) == 0 || is.inkSplotches[len(is.inkSplotches)-1].IsFull() {
+			if err := is.addSplotch(); err != nil {
+				is.rollbackBatch(mark)
+				return nil, err
+			}
+		}
+		keys[i] = first.Plus(i)
+		if err := is.inkSplotches[len(is.inkSplotches)-1].Append(storedItem{Key: keys[i], Value: data}); err != nil {
+			is.rollbackBatch(mark)
+			return nil, err
+		}
+	}
+	is.largestKey = keys[len(keys)-1]
+	is.usedBytes += int64(total)
+	return keys, nil
+}
+
+func (is *inkSack) markBatch() batchMark {
+	mark := batchMark{
+		splotches:  len(is.inkSplotches),
+		largestKey: is.largestKey,
+		usedBytes:  is.usedBytes,
+		changed:    is.manifestChanged,
+	}
+	if mark.splotches != 0 {
+		mark.last = is.inkSplotches[mark.splotches-1]
+		mark.headings = mark.last.headings
+		mark.smallest = mark.last.smallestKey
+		mark.unsaved = len(mark.last.unsavedItems)
+		mark.stored = len(mark.last.storedItems)
+	}
+	return mark
+}
+
+// undoes everything a failed batch added. Any splotches it made are removed, files and all.
+func (is *inkSack) rollbackBatch(mark batchMark) {
+	for i := mark.splotches; i < len(is.inkSplotches); i++ {
+		if err := os.Remove(is.inkSplotches[i].fileLocation); err != nil {
+			is.logger.Warn("couldn't remove splotch left by a failed batch", zap.String("splotch", is.manifest.Splotches[i].Name), zap.Error(err))
+		}
+	}
+	is.inkSplotches = is.inkSplotches[:mark.splotches]
+	is.manifest.Splotches = is.manifest.Splotches[:mark.splotches]
+	if mark.last != nil {
+		mark.last.headings = mark.headings
+		mark.last.smallestKey = mark.smallest
+		mark.last.unsavedItems = mark.last.unsavedItems[:mark.unsaved]
+		mark.last.storedItems = mark.last.storedItems[:mark.stored]
+	}
+	is.largestKey = mark.largestKey
+	is.usedBytes = mark.usedBytes
+	is.manifestChanged = mark.changed
+}
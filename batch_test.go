@@ -0,0 +1,126 @@
+package inkdb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkDBAppendBatch(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("table", &testableObject{}, WithRollover(RolloverPolicy{MaxRows: 4})); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := ink.Append("table", generateTestableObject(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	items := []any{}
+	for i := 2; i < 12; i++ {
+		items = append(items, generateTestableObject(i))
+	}
+	keys, err := ink.AppendBatch("table", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//one after another, rolling over into new splotches along the way.
+	assert.Len(t, keys, 10)
+	for i, key := range keys {
+		assert.Equal(t, KeyFromUint64(uint64(i+3)), key)
+	}
+	stats, _ := ink.Stats("table")
+	assert.Equal(t, 3, stats.Splotches)
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink2.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	found, foundKeys, err := ink2.Get("table", SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, found, 12)
+	for i := range found {
+		assert.Equal(t, generateTestableObject(i), found[i])
+		assert.Equal(t, KeyFromUint64(uint64(i+1)), foundKeys[i])
+	}
+
+	//a batch that can't all fit adds nothing at all.
+	ink2.inkSacks["table"].minFreeSpace = 1 << 62
+	_, err = ink2.AppendBatch("table", items)
+	assert.True(t, errors.Is(err, ErrInsufficientSpace))
+	stats, _ = ink2.Stats("table")
+	assert.Equal(t, 12, stats.Records)
+	assert.Equal(t, 3, stats.Splotches)
+	assert.Equal(t, KeyFromUint64(12), stats.LargestKey)
+}
+
+// values holding interfaces can't share type info, but still need to come out decodable.
+func TestGobEncodeBatch(t *testing.T) {
+	type holder struct {
+		Value any
+	}
+	items := []any{generateTestableObject(1), generateTestableObject(2), holder{Value: 1}, holder{Value: "two"}, generateTestableObject(3)}
+	encoded, err := GobCodec{}.EncodeBatch(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, item := range items {
+		switch expected := item.(type) {
+		case *testableObject:
+			decoded := &testableObject{}
+			assert.NoError(t, GobCodec{}.Decode(encoded[i], decoded))
+			assert.Equal(t, expected, decoded)
+		case holder:
+			decoded := holder{}
+			assert.NoError(t, GobCodec{}.Decode(encoded[i], &decoded))
+			assert.Equal(t, expected, decoded)
+		}
+	}
+}
+
+func BenchmarkInkDBAppend(b *testing.B) {
+	ink, err := NewInkDB(getInkTestFile())
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := ink.NewTable("table", &testableObject{}); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ink.Append("table", generateTestableObject(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInkDBAppendBatch(b *testing.B) {
+	ink, err := NewInkDB(getInkTestFile())
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := ink.NewTable("table", &testableObject{}); err != nil {
+		b.Fatal(err)
+	}
+	items := make([]any, b.N)
+	for i := range items {
+		items[i] = generateTestableObject(i)
+	}
+	b.ResetTimer()
+	if _, err := ink.AppendBatch("table", items); err != nil {
+		b.Fatal(err)
+	}
+}
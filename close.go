@@ -0,0 +1,111 @@
+package inkdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+
+	"go.uber.org/zap"
+)
+
+// the file every open database holds a lock on, so only one process writes to it at a time.
+const lockFileName = "inkdb.lock"
+
+// takes the lock for the database at dir, creating the folder if it needs to.
+func lockDatabase(dir string) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path.Join(dir, lockFileName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if err == ErrLocked {
+			return nil, fmt.Errorf("%w: %v is already open somewhere else", ErrLocked, dir)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// returns ErrClosed once the database has been closed.
+func (ink *InkDB) checkOpen() error {
+	ink.mu.RLock()
+	defer ink.mu.RUnlock()
+	if ink.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// commits anything still waiting, stops the background commits, and lets go of the database's folder so something
+// else can open it. Everything called on it afterwards fails with ErrClosed.
+// if the last commit fails, the database is left open (and locked) as it was, and Close can be tried again.
+func (ink *InkDB) Close() error {
+	ink.mu.Lock()
+	if ink.closed || ink.closing {
+		ink.mu.Unlock()
+		return ErrClosed
+	}
+	ink.closing = true
+	sacks := make([]*inkSack, 0, len(ink.inkSacks))
+	for _, sack := range ink.inkSacks {
+		sacks = append(sacks, sack)
+	}
+	ink.mu.Unlock()
+	//anything part way through adding to a table finishes first, and nothing new gets in while the last commit runs.
+	setSacksClosed(sacks, true)
+	if err := ink.finalCommit(); err != nil {
+		setSacksClosed(sacks, false)
+		ink.mu.Lock()
+		ink.closing = false
+		ink.mu.Unlock()
+		ink.logger.Error("couldn't close database", zap.String("location", ink.fileStartPoint), zap.Error(err))
+		return err
+	}
+	ink.mu.Lock()
+	ink.closing = false
+	ink.closed = true
+	ink.mu.Unlock()
+
+	//everything is committed by now, so the background work has nothing left to do.
+	ink.maintenance.close()
+	var err error
+	if ink.flusher != nil {
+		err = ink.flusher.close()
+	}
+	if ink.lockFile != nil {
+		unlockFile(ink.lockFile)
+		if closeErr := ink.lockFile.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	ink.logger.Info("closed database", zap.String("location", ink.fileStartPoint), zap.Error(err))
+	return err
+}
+
+// commits everything for Close. With auto commit, it goes through the flusher, so the flusher has nothing left waiting.
+func (ink *InkDB) finalCommit() error {
+	if ink.readOnly {
+		return nil
+	}
+	if ink.flusher != nil {
+		if flushed, err := ink.flusher.flush(); flushed {
+			return err
+		}
+	}
+	return ink.commitCtx(context.Background())
+}
+
+// turns appends (and everything else) away from sacks, or lets them back in. Anything already part way through
+// finishes first.
+func setSacksClosed(sacks []*inkSack, closed bool) {
+	for _, sack := range sacks {
+		sack.mu.Lock()
+		sack.closed = closed
+		sack.mu.Unlock()
+	}
+}
@@ -0,0 +1,104 @@
+package inkdb
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkDBClose(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Append("table", generateTestableObject(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	//only one writer at a time.
+	_, err = NewInkDB(folder)
+	assert.True(t, errors.Is(err, ErrLocked))
+
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assert.ErrorIs(t, ink.Append("table", generateTestableObject(1)), ErrClosed)
+	_, _, err = ink.Get("table", SplotchKey{}, MaxSplotchKey)
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.ErrorIs(t, ink.Commit(), ErrClosed)
+	assert.ErrorIs(t, ink.Flush(), ErrClosed)
+	assert.ErrorIs(t, ink.NewTable("other", &testableObject{}), ErrClosed)
+	assert.ErrorIs(t, ink.DropTable("table"), ErrClosed)
+	assert.ErrorIs(t, ink.Close(), ErrClosed)
+
+	//closing let go of the folder, and committed what was appended.
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink2.Close()
+	if err := ink2.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	found, _, err := ink2.Get("table", SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []any{generateTestableObject(0)}, found)
+}
+
+func TestInkDBCloseFailedCommit(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Append("table", generateTestableObject(0)); err != nil {
+		t.Fatal(err)
+	}
+	//with the table's folder gone, there's nowhere to commit it to.
+	tableFolder := path.Join(folder, "inksacks", "table")
+	if err := os.RemoveAll(tableFolder); err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, ink.Close())
+
+	//so it's still open, and still holds the lock.
+	_, err = NewInkDB(folder)
+	assert.ErrorIs(t, err, ErrLocked)
+	if err := ink.Append("table", generateTestableObject(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	//once there's somewhere to commit to again, it can be closed.
+	if err := os.MkdirAll(path.Join(tableFolder, "splotches"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assert.ErrorIs(t, ink.Append("table", generateTestableObject(2)), ErrClosed)
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink2.Close()
+	if err := ink2.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	found, _, err := ink2.Get("table", SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []any{generateTestableObject(0), generateTestableObject(1)}, found)
+}
@@ -0,0 +1,96 @@
+// a small command line tool for getting data in and out of an InkDB folder.
+//
+//	inkdb export <db folder> <table> <jsonl|csv>              writes the table to stdout
+//	inkdb import [-keep-keys] <db folder> <table> <jsonl|csv>  reads the table from stdin
+//	inkdb restore <dest> <full backup> [incrementals...]       puts a chain of backups back together at dest
+//
+// the tool doesn't know the Go types the tables hold, so values are exported as they're stored. Tables using the json
+// codec come out as plain json, anything else as base64 of the encoded bytes.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"inkdb"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = export(os.Args[2:])
+	case "import":
+		err = importTable(os.Args[2:])
+	case "restore":
+		err = restore(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	fmt.Fprintln(os.Stderr, "  inkdb export <db folder> <table> <jsonl|csv>")
+	fmt.Fprintln(os.Stderr, "  inkdb import [-keep-keys] <db folder> <table> <jsonl|csv>")
+	fmt.Fprintln(os.Stderr, "  inkdb restore <dest> <full backup> [incrementals...]")
+	os.Exit(2)
+}
+
+func export(args []string) error {
+	if len(args) != 3 {
+		usage()
+	}
+	format, err := inkdb.ParseExportFormat(args[2])
+	if err != nil {
+		return err
+	}
+	//read only, so it can export from a database that's still being written to.
+	ink, err := inkdb.OpenReadOnly(args[0])
+	if err != nil {
+		return err
+	}
+	defer ink.Close()
+	return ink.ExportTable(args[1], os.Stdout, format)
+}
+
+func importTable(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	keepKeys := flags.Bool("keep-keys", false, "keep the keys from the input, instead of giving each item a new one")
+	flags.Parse(args)
+	if flags.NArg() != 3 {
+		usage()
+	}
+	format, err := inkdb.ParseExportFormat(flags.Arg(2))
+	if err != nil {
+		return err
+	}
+	ink, err := inkdb.NewInkDB(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	//a table that isn't there yet just gets made, without a type.
+	if err := ink.NewTable(flags.Arg(1), nil); err != nil {
+		return err
+	}
+	if err := ink.ImportTable(flags.Arg(1), os.Stdin, format, *keepKeys); err != nil {
+		return err
+	}
+	return ink.Close()
+}
+
+// the backups go oldest first, starting with the full one.
+func restore(args []string) error {
+	if len(args) < 2 {
+		usage()
+	}
+	return inkdb.Restore(args[0], args[1:]...)
+}
@@ -0,0 +1,165 @@
+package inkdb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// turns the items put into a table into bytes, and back again.
+// the name is what gets saved with the table, so it needs to stay the same between runs.
+type Codec interface {
+	Name() string
+	Encode(item any) ([]byte, error)
+	Decode(data []byte, into any) error
+}
+
+// the default codec. Every value is its own gob stream, so each one carries its own type info.
+type GobCodec struct{}
+
+func (GobCodec) Name() string { return "gob" }
+func (GobCodec) Encode(item any) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	if err := gob.NewEncoder(buffer).Encode(item); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+func (GobCodec) Decode(data []byte, into any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(into)
+}
+
+// stores values as json. Bigger than gob, but readable by anything.
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string { return "json" }
+func (JSONCodec) Encode(item any) ([]byte, error) {
+	return json.Marshal(item)
+}
+func (JSONCodec) Decode(data []byte, into any) error {
+	return json.Unmarshal(data, into)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		GobCodec{}.Name():  GobCodec{},
+		JSONCodec{}.Name(): JSONCodec{},
+	}
+)
+
+// makes a codec available to tables by its name. A custom codec needs registering before WithCodec can use it, and
+// again after every restart before its tables are used.
+func RegisterCodec(codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[codec.Name()] = codec
+}
+
+// finds the codec saved under name. An empty name is the default gob codec.
+func lookupCodec(name string) (Codec, error) {
+	if name == "" {
+		return GobCodec{}, nil
+	}
+	codecsMu.RLock()
+	codec, ok := codecs[name]
+	codecsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no codec registered under %v", name)
+	}
+	return codec, nil
+}
+
+// how the encoded values are compressed before being stored.
+type Compression int
+
+const (
+	NoCompression Compression = iota
+	GzipCompression
+)
+
+// compresses an already encoded value
+func (c Compression) compress(data []byte) ([]byte, error) {
+	switch c {
+	case NoCompression:
+		return data, nil
+	case GzipCompression:
+		buffer := &bytes.Buffer{}
+		w := gzip.NewWriter(buffer)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unknown compression %v", int(c))
+}
+
+// undoes compress
+func (c Compression) decompress(data []byte) ([]byte, error) {
+	switch c {
+	case NoCompression:
+		return data, nil
+	case GzipCompression:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	}
+	return nil, fmt.Errorf("unknown compression %v", int(c))
+}
+
+// makes a fresh value of the same type as the prototype, so decoding never writes over a value that was already returned.
+func newOfType(prototype any) any {
+	t := reflect.TypeOf(prototype)
+	if t.Kind() == reflect.Pointer {
+		return reflect.New(t.Elem()).Interface()
+	}
+	return reflect.New(t).Interface()
+}
+
+// everything needed to turn a table's values into bytes and back.
+type valueFormat struct {
+	table       string
+	codec       Codec
+	compression Compression
+	prototype   any //the type values are decoded into
+}
+
+// turns an item into the bytes stored for it, using the table's codec and compression.
+func (vf valueFormat) encode(item any) ([]byte, error) {
+	data, err := vf.codec.Encode(item)
+	if err != nil {
+		return nil, err
+	}
+	return vf.compression.compress(data)
+}
+
+// turns stored bytes back into a fresh value of the table's type.
+func (vf valueFormat) decode(data []byte) (any, error) {
+	data, err := vf.compression.decompress(data)
+	if err != nil {
+		return nil, err
+	}
+	return vf.decodeEncoded(data)
+}
+
+// the same as decode, for bytes that have already been decompressed, so they're just what the codec wrote.
+func (vf valueFormat) decodeEncoded(data []byte) (any, error) {
+	if vf.prototype == nil {
+		return nil, fmt.Errorf("no type given for inksack %v, it needs NewTable called on it", vf.table)
+	}
+	value := newOfType(vf.prototype)
+	if err := vf.codec.Decode(data, value); err != nil {
+		return nil, err
+	}
+	return vf.matchPrototype(value), nil
+}
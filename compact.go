@@ -0,0 +1,358 @@
+package inkdb
+
+import (
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// what a compaction did.
+type CompactReport struct {
+	SplotchesBefore int
+	SplotchesAfter  int
+	Written         int //how many new splotches were written, each one taking the place of several old ones
+	RecordsDropped  int //records left out, for being tombstoned past their grace period or an old version
+}
+
+// one run of neighbouring splotches that get replaced by a single new one.
+type compactGroup struct {
+	first, last int             //which splotches, inclusive
+	old         []manifestEntry //their entries, as of when the compaction was planned
+	entry       manifestEntry   //where the new splotch goes. No name if nothing in the group is kept, so there's nothing to write.
+	headings    fileHeadings    //what the new splotch's headings will be
+	oldBytes    int64           //the bytes of values the old splotches held between them
+	dropped     []SplotchKey    //the records that were left out
+}
+
+// merges runs of neighbouring splotches that aren't full into full ones, and gets rid of empty ones. Tombstoned records
+// past their grace period, and old versions in latest value tables, are left out along the way.
+// the new splotches are written first, then swapped in through the manifest, and only then are the old files removed.
+// reads (and appends) carry on as normal the whole time. Only commits have to wait for it to finish.
+func (ink *InkDB) Compact(table string) (CompactReport, error) {
+	if err := ink.checkWritable(); err != nil {
+		return CompactReport{}, err
+	}
+	sack, format, err := ink.getSackAndFormat(table)
+	if err != nil {
+		return CompactReport{}, err
+	}
+	//nothing else gets to rewrite files while this is going on. Appends only ever touch the last splotch, which is left alone.
+	ink.commitMu.Lock()
+	defer ink.commitMu.Unlock()
+	if err := sack.lock(); err != nil {
+		return CompactReport{}, err
+	}
+	//only what's on the disc gets compacted, so get everything there first.
+	if err := sack.Commit(); err != nil {
+		sack.mu.Unlock()
+		return CompactReport{}, err
+	}
+	report := CompactReport{SplotchesBefore: len(sack.inkSplotches)}
+	filter := compactFilter{tombstoned: sack.expiredTombstones(time.Now())}
+	if sack.metadata.LatestBy != "" {
+		if filter.newest, err = sack.newestKeys(format); err != nil {
+			sack.mu.Unlock()
+			return CompactReport{}, err
+		}
+		filter.latest = true
+	}
+	groups := sack.planCompaction(filter)
+	sack.mu.Unlock()
+	if len(groups) == 0 {
+		report.SplotchesAfter = report.SplotchesBefore
+		return report, nil
+	}
+
+	if err := sack.compact(groups, filter); err != nil {
+		sack.logger.Error("compaction failed", zap.Error(err))
+		return report, err
+	}
+	report.SplotchesAfter = report.SplotchesBefore
+	for _, group := range groups {
+		report.SplotchesAfter -= len(group.old)
+		report.RecordsDropped += len(group.dropped)
+		if group.entry.Name != "" {
+			report.SplotchesAfter++
+			report.Written++
+		}
+	}
+	ink.logger.Info("compacted table", zap.String("table", table),
+		zap.Int("splotchesBefore", report.SplotchesBefore), zap.Int("splotchesAfter", report.SplotchesAfter), zap.Int("recordsDropped", report.RecordsDropped))
+	return report, nil
+}
+
+// what a compaction leaves out of the splotches it writes.
+type compactFilter struct {
+	tombstoned map[SplotchKey]bool //keys tombstoned for longer than the grace period
+	newest     []SplotchKey        //for latest value tables, the newest key for each ID, in order. Every other key is an old version.
+	latest     bool                //if newest is being used
+}
+
+// if the record under key gets left out.
+func (f compactFilter) drops(key SplotchKey) bool {
+	if f.tombstoned[key] {
+		return true
+	}
+	if !f.latest {
+		return false
+	}
+	i := sort.Search(len(f.newest), func(i int) bool { return f.newest[i].GreaterOrEqual(key) })
+	return i == len(f.newest) || f.newest[i] != key
+}
+
+// a guess at how many of the splotch's records get left out. Tombstones can be for keys that aren't really there,
+// so it's only used to decide what to merge. The real count comes once the records have been read.
+func (f compactFilter) countDropping(splotch *inkSplotch) int {
+	if splotch.headings.LinesStored == 0 {
+		return 0
+	}
+	smallest, largest := splotch.smallestKey, splotch.headings.LargestKey
+	count := 0
+	for key := range f.tombstoned {
+		if key.GreaterOrEqual(smallest) && key.LessOrEqual(largest) {
+			count++
+		}
+	}
+	if f.latest {
+		start := sort.Search(len(f.newest), func(i int) bool { return f.newest[i].GreaterOrEqual(smallest) })
+		end := sort.Search(len(f.newest), func(i int) bool { return f.newest[i].GreaterThan(largest) })
+		count += splotch.headings.LinesStored - (end - start)
+	}
+	if count > splotch.headings.LinesStored {
+		return splotch.headings.LinesStored
+	}
+	return count
+}
+
+// works out which runs of splotches can be merged. The last splotch is still being filled, so it's never included.
+// a splotch on its own is only rewritten if it's empty, or has something to leave out.
+func (is *inkSack) planCompaction(filter compactFilter) []compactGroup {
+	groups := []compactGroup{}
+	closed := len(is.inkSplotches) - 1
+	for i := 0; i < closed; {
+		if is.manifest.Splotches[i].archived() {
+			//archives are left as they are.
+			i++
+			continue
+		}
+		last, headings := i, is.inkSplotches[i].headings
+		//a guess at how many rows will be left, going by the tombstones. The real count comes once they've been read.
+		live, dropped := headings, filter.countDropping(is.inkSplotches[i])
+		live.LinesStored -= dropped
+		for last+1 < closed && !is.manifest.Splotches[last+1].archived() {
+			next := is.inkSplotches[last+1]
+			nextDropped := filter.countDropping(next)
+			nextLive := next.headings
+			nextLive.LinesStored -= nextDropped
+			if !is.metadata.Rollover.canHold(mergeHeadings(live, nextLive)) {
+				break
+			}
+			last, headings, live = last+1, mergeHeadings(headings, next.headings), mergeHeadings(live, nextLive)
+			dropped += nextDropped
+		}
+		if last > i || headings.LinesStored == 0 || dropped != 0 {
+			group := compactGroup{
+				first:    i,
+				last:     last,
+				old:      append([]manifestEntry{}, is.manifest.Splotches[i:last+1]...),
+				headings: headings,
+				oldBytes: headings.BytesStored,
+			}
+			if headings.LinesStored != 0 {
+				//stays in the same place as the oldest one, so hot and cold tiers keep their splotches.
+				group.entry = manifestEntry{DataDir: group.old[0].DataDir, Name: is.manifest.nextName()}
+			}
+			groups = append(groups, group)
+		}
+		i = last + 1
+	}
+	return groups
+}
+
+// the headings a splotch holding everything from a, then everything from b, would have.
+func mergeHeadings(a, b fileHeadings) fileHeadings {
+	merged := fileHeadings{
+		LargestKey:    b.LargestKey,
+		LinesStored:   a.LinesStored + b.LinesStored,
+		BytesStored:   a.BytesStored + b.BytesStored,
+		FirstAppended: a.FirstAppended,
+		LastAppended:  a.LastAppended,
+	}
+	if merged.FirstAppended == 0 || (b.FirstAppended != 0 && b.FirstAppended < merged.FirstAppended) {
+		merged.FirstAppended = b.FirstAppended
+	}
+	if b.LastAppended > merged.LastAppended {
+		merged.LastAppended = b.LastAppended
+	}
+	return merged
+}
+
+// writes out the new splotches for groups, swaps them in, and removes the old ones. The sack is only locked for the swap.
+func (is *inkSack) compact(groups []compactGroup, filter compactFilter) error {
+	needed := map[string]int64{}
+	for _, group := range groups {
+		if group.entry.Name == "" {
+			continue
+		}
+		size := splotchHeaderSize + 512 + group.headings.BytesStored + 32*int64(group.headings.LinesStored)
+		for _, location := range is.entryCopies(group.entry) {
+			needed[path.Dir(location)] += size
+		}
+	}
+	if err := is.checkSpace(needed); err != nil {
+		return err
+	}
+	for i := range groups {
+		if err := is.writeCompacted(&groups[i], filter); err != nil {
+			is.removeCompacted(groups)
+			return err
+		}
+	}
+
+	if err := is.lock(); err != nil {
+		is.removeCompacted(groups)
+		return err
+	}
+	if err := is.swapCompacted(groups); err != nil {
+		is.mu.Unlock()
+		is.removeCompacted(groups)
+		return err
+	}
+	is.mu.Unlock()
+
+	for _, group := range groups {
+		for _, entry := range group.old {
+			for _, location := range is.entryCopies(entry) {
+				if err := os.Remove(location); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// writes the merged splotch for group, along with its mirrors, and records its checksum. Anything filter drops is left out.
+func (is *inkSack) writeCompacted(group *compactGroup, filter compactFilter) error {
+	if group.entry.Name == "" {
+		return nil
+	}
+	items := make([]*storedItem, 0, group.headings.LinesStored)
+	for _, entry := range group.old {
+		//these are all closed, so nothing changes them while they're read.
+		location, _ := is.healthyCopy(entry)
+		old := &inkSplotch{fileLocation: location, logger: is.logger.With(zap.String("splotch", entry.Name))}
+		if err := old.FullyLoad(); err != nil {
+			return err
+		}
+		for _, item := range old.storedItems {
+			if filter.drops(item.Key) {
+				group.dropped = append(group.dropped, item.Key)
+				continue
+			}
+			items = append(items, item)
+		}
+	}
+	//the real counts, now the dropped ones are known. It's a new file, so nothing has been written to it yet either.
+	group.headings.LinesStored = len(items)
+	group.headings.BytesStored = 0
+	group.headings.DataEnd = 0
+	for _, item := range items {
+		group.headings.BytesStored += int64(len(item.Value))
+	}
+	if len(items) == 0 {
+		//everything was dropped, so there's nothing to write.
+		group.entry = manifestEntry{}
+		return nil
+	}
+	merged := &inkSplotch{
+		fileLocation: is.entryLocation(group.entry),
+		headings:     group.headings,
+		unsavedItems: items,
+		fileMode:     is.metadata.fileMode(),
+		syncOnSave:   true, //it has to be on the disc before the manifest points at it
+		metrics:      is.metrics,
+		logger:       is.logger.With(zap.String("splotch", group.entry.Name)),
+	}
+	if err := merged.SaveToFile(); err != nil {
+		return err
+	}
+	if len(is.metadata.Mirrors) == 0 {
+		return nil
+	}
+	sum, err := fileChecksum(merged.fileLocation)
+	if err != nil {
+		return err
+	}
+	for _, mirror := range is.metadata.Mirrors {
+		if err := copyFile(merged.fileLocation, is.mirrorLocation(mirror, group.entry), is.metadata.fileMode()); err != nil {
+			return err
+		}
+	}
+	group.entry.Checksum, group.entry.Summed = sum, true
+	return nil
+}
+
+// puts the new splotches in place of the old ones, in memory and in the manifest. Nothing changes if the manifest can't be saved.
+// splotches can only have been added on the end since the compaction was planned, so the groups still line up.
+func (is *inkSack) swapCompacted(groups []compactGroup) error {
+	splotches := []*inkSplotch{}
+	entries := []manifestEntry{}
+	from := 0
+	for _, group := range groups {
+		splotches = append(splotches, is.inkSplotches[from:group.first]...)
+		entries = append(entries, is.manifest.Splotches[from:group.first]...)
+		if group.entry.Name != "" {
+			splotch, err := is.newSplotch(group.entry)
+			if err != nil {
+				return err
+			}
+			splotches = append(splotches, splotch)
+			entries = append(entries, group.entry)
+		}
+		from = group.last + 1
+	}
+	splotches = append(splotches, is.inkSplotches[from:]...)
+	entries = append(entries, is.manifest.Splotches[from:]...)
+
+	oldEntries := is.manifest.Splotches
+	is.manifest.Splotches = entries
+	if err := is.saveManifest(); err != nil {
+		is.manifest.Splotches = oldEntries
+		return err
+	}
+	is.inkSplotches = splotches
+	for _, group := range groups {
+		is.usedBytes -= group.oldBytes - group.headings.BytesStored
+	}
+	//the records they hid are gone now, so the tombstones can go too.
+	gone := map[SplotchKey]bool{}
+	for _, group := range groups {
+		for _, key := range group.dropped {
+			gone[key] = true
+		}
+	}
+	if err := is.dropTombstones(func(key SplotchKey, _ int64) bool { return gone[key] }); err != nil {
+		//they'll only be hiding records that aren't there any more.
+		is.logger.Warn("couldn't remove tombstones after compacting", zap.Error(err))
+	}
+	return nil
+}
+
+// cleans up after a compaction that didn't make it, removing every new splotch it wrote.
+func (is *inkSack) removeCompacted(groups []compactGroup) {
+	for _, group := range groups {
+		if group.entry.Name == "" {
+			continue
+		}
+		for _, location := range is.entryCopies(group.entry) {
+			if err := os.Remove(location); err != nil && !errors.Is(err, os.ErrNotExist) {
+				is.logger.Warn("couldn't remove splotch left by a failed compaction", zap.String("location", location), zap.Error(err))
+			}
+		}
+	}
+}
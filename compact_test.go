@@ -0,0 +1,97 @@
+package inkdb
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkDBCompact(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mirror := path.Join(folder, "compactMirror")
+	if err := ink.NewTable("table", []byte{}, WithCodec(rawCodec{}), WithRollover(RolloverPolicy{MaxRows: 2}), WithMirrors(mirror)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := ink.Append("table", []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//five splotches of two. Letting them hold more leaves the first four mostly empty.
+	if err := ink.SetRollover("table", RolloverPolicy{MaxRows: 8}); err != nil {
+		t.Fatal(err)
+	}
+
+	//compacting part way through a scan shouldn't lose or repeat anything.
+	seen := []SplotchKey{}
+	err = ink.Scan("table", KeyFromUint64(0), KeyFromUint64(100), func(key SplotchKey, value any) error {
+		if len(seen) == 0 {
+			report, err := ink.Compact("table")
+			if err != nil {
+				return err
+			}
+			assert.Equal(t, CompactReport{SplotchesBefore: 5, SplotchesAfter: 2, Written: 1}, report)
+		}
+		seen = append(seen, key)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, seen, 10)
+	for i, key := range seen {
+		assert.Equal(t, KeyFromUint64(uint64(i+1)), key)
+	}
+
+	files, _ := os.ReadDir(path.Join(folder, "inksacks", "table", "splotches"))
+	assert.Len(t, files, 2)
+	mirrored, _ := os.ReadDir(path.Join(mirror, "table", "splotches"))
+	assert.Len(t, mirrored, 2)
+	scrub, err := ink.Scrub("table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, scrub.Unrecoverable)
+	assert.Empty(t, scrub.Repaired)
+
+	//nothing left to merge.
+	report, err := ink.Compact("table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, report.Written)
+	if err := ink.Append("table", []byte{10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink2.Close()
+	if err := ink2.NewTable("table", []byte{}); err != nil {
+		t.Fatal(err)
+	}
+	values, keys, err := ink2.Get("table", KeyFromUint64(0), KeyFromUint64(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, keys, 11)
+	for i, value := range values {
+		assert.Equal(t, []byte{byte(i)}, value)
+	}
+	stats, _ := ink2.Stats("table")
+	assert.Equal(t, 2, stats.Splotches)
+
+	_, err = ink2.Compact("missing")
+	assert.ErrorIs(t, err, ErrNoTable)
+}
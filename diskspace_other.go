@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package inkdb
+
+// there's no free space check on this system yet, so it's always unknown.
+func freeSpace(dir string) (free uint64, known bool) {
+	return 0, false
+}
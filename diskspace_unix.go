@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd
+
+package inkdb
+
+import "syscall"
+
+// how many bytes are free for us to use on the drive holding dir. known is false if it couldn't be found out.
+func freeSpace(dir string) (free uint64, known bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, false
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), true
+}
@@ -5,4 +5,13 @@ import "fmt"
 var (
 	ErrSplotchRangeExceeded = fmt.Errorf("outside splotch range")
 	ErrSplotchFull          = fmt.Errorf("splotch full already")
+	ErrInsufficientSpace    = fmt.Errorf("not enough free disc space")
+	ErrQuotaExceeded        = fmt.Errorf("storage quota exceeded")
+	ErrNoTable              = fmt.Errorf("no inksack(table) found")
+	ErrClosed               = fmt.Errorf("database is closed")
+	ErrLocked               = fmt.Errorf("database is locked by another writer")
+	ErrReadOnly             = fmt.Errorf("database is open read only")
+	ErrNotFound             = fmt.Errorf("no record found")
+	ErrTableNotEmpty        = fmt.Errorf("table already holds records")
+	ErrChecksumMismatch     = fmt.Errorf("splotch doesn't match its checksum")
 )
@@ -0,0 +1,364 @@
+package inkdb
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// the formats a table can be exported to, and imported from.
+type ExportFormat int
+
+const (
+	//one json object per line, like {"key":1,"value":{...}}
+	JSONLines ExportFormat = iota
+	//a key column, then a column for each field of the table's type
+	CSV
+)
+
+// finds the format for a name like "jsonl" or "csv".
+func ParseExportFormat(name string) (ExportFormat, error) {
+	switch strings.ToLower(name) {
+	case "jsonl", "jsonlines", "ndjson":
+		return JSONLines, nil
+	case "csv":
+		return CSV, nil
+	}
+	return 0, fmt.Errorf("unknown export format %v", name)
+}
+
+// one line of a JSON Lines export. Tables without a type to decode into can't give a value, so they give the raw
+// encoded bytes instead. Unless they're stored as json already, in which case that's just passed along.
+type exportRecord struct {
+	Key   uint64          `json:"key"`
+	Value json.RawMessage `json:"value,omitempty"`
+	Raw   []byte          `json:"raw,omitempty"`
+}
+
+// writes every item in the table to w, along with its key.
+// values are decoded through the table's type. If the table hasn't been given one, they're exported as they're stored.
+// like Scan, the table is read a splotch at a time, and splotches that weren't already loaded don't stay that way.
+func (ink *InkDB) ExportTable(table string, w io.Writer, format ExportFormat) error {
+	sack, vf, err := ink.getSackAndFormat(table)
+	if err != nil {
+		return err
+	}
+	each := func(fn func(item storedItem) error) error {
+		return sack.scanStored(context.Background(), SplotchKey{}, MaxSplotchKey, sack.peekSplotch, fn)
+	}
+	switch format {
+	case JSONLines:
+		return exportJSONLines(vf, each, w)
+	case CSV:
+		return exportCSV(vf, each, w)
+	}
+	return fmt.Errorf("unknown export format %v", int(format))
+}
+
+// reads items from r into the table. With keepKeys, each item keeps the key it was exported with (like PLACE),
+// so they need to be larger than anything already in the table. Otherwise they're given new keys, like Append.
+func (ink *InkDB) ImportTable(table string, r io.Reader, format ExportFormat, keepKeys bool) error {
+	if err := ink.checkWritable(); err != nil {
+		return err
+	}
+	sack, vf, err := ink.getSackAndFormat(table)
+	if err != nil {
+		return err
+	}
+	store := func(record exportRecord) error {
+		data, err := vf.importValue(record)
+		if err != nil {
+			return err
+		}
+		if keepKeys {
+			return ink.placeStored(sack, storedItem{Key: KeyFromUint64(record.Key), Value: data})
+		}
+		return ink.appendStored(sack, data)
+	}
+	switch format {
+	case JSONLines:
+		return importJSONLines(r, store)
+	case CSV:
+		return importCSV(vf, r, store)
+	}
+	return fmt.Errorf("unknown export format %v", int(format))
+}
+
+// turns a stored item into what gets exported for it.
+func (vf valueFormat) exportValue(item storedItem) (exportRecord, error) {
+	record := exportRecord{Key: item.Key.Uint64()}
+	if vf.prototype != nil {
+		value, err := vf.decode(item.Value)
+		if err != nil {
+			return record, err
+		}
+		record.Value, err = json.Marshal(value)
+		return record, err
+	}
+	data, err := vf.compression.decompress(item.Value)
+	if err != nil {
+		return record, err
+	}
+	if vf.codec.Name() == (JSONCodec{}).Name() {
+		record.Value = data
+	} else {
+		record.Raw = data
+	}
+	return record, nil
+}
+
+// turns an imported record back into the bytes stored for it. The opposite of exportValue.
+func (vf valueFormat) importValue(record exportRecord) ([]byte, error) {
+	if record.Raw != nil {
+		return vf.compression.compress(record.Raw)
+	}
+	if vf.prototype == nil {
+		if vf.codec.Name() != (JSONCodec{}).Name() {
+			return nil, fmt.Errorf("inksack %v needs a type to import values into", vf.table)
+		}
+		return vf.compression.compress(record.Value)
+	}
+	value := newOfType(vf.prototype)
+	if err := json.Unmarshal(record.Value, value); err != nil {
+		return nil, err
+	}
+	return vf.encode(vf.matchPrototype(value))
+}
+
+// values are always decoded into pointers. If the table was given a plain value as its type, this gets back to that.
+func (vf valueFormat) matchPrototype(value any) any {
+	if reflect.TypeOf(vf.prototype).Kind() != reflect.Pointer {
+		return reflect.ValueOf(value).Elem().Interface()
+	}
+	return value
+}
+
+func exportJSONLines(vf valueFormat, each func(fn func(item storedItem) error) error, w io.Writer) error {
+	buffered := bufio.NewWriter(w)
+	enc := json.NewEncoder(buffered)
+	err := each(func(item storedItem) error {
+		record, err := vf.exportValue(item)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(record)
+	})
+	if err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+func importJSONLines(r io.Reader, store func(exportRecord) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		var record exportRecord
+		err := dec.Decode(&record)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := store(record); err != nil {
+			return err
+		}
+	}
+}
+
+// the struct type the table's values are, if they are one. Their fields get a column each in a csv.
+func (vf valueFormat) structType() (reflect.Type, bool) {
+	if vf.prototype == nil {
+		return nil, false
+	}
+	t := reflect.TypeOf(vf.prototype)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t, t.Kind() == reflect.Struct
+}
+
+// the exported fields of a struct type, which are all that get a column.
+func csvFields(t reflect.Type) []reflect.StructField {
+	fields := []reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			fields = append(fields, t.Field(i))
+		}
+	}
+	return fields
+}
+
+func exportCSV(vf valueFormat, each func(fn func(item storedItem) error) error, w io.Writer) error {
+	out := csv.NewWriter(w)
+	structType, isStruct := vf.structType()
+	var fields []reflect.StructField
+	header := []string{"key"}
+	switch {
+	case isStruct:
+		fields = csvFields(structType)
+		for _, field := range fields {
+			header = append(header, field.Name)
+		}
+	case vf.prototype == nil && vf.codec.Name() != (JSONCodec{}).Name():
+		header = append(header, "raw")
+	default:
+		header = append(header, "value")
+	}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	err := each(func(item storedItem) error {
+		record, err := vf.exportValue(item)
+		if err != nil {
+			return err
+		}
+		row := []string{strconv.FormatUint(record.Key, 10)}
+		switch {
+		case isStruct:
+			value, err := vf.decode(item.Value)
+			if err != nil {
+				return err
+			}
+			structValue := reflect.Indirect(reflect.ValueOf(value))
+			for _, field := range fields {
+				cell, err := csvCell(structValue.FieldByIndex(field.Index))
+				if err != nil {
+					return err
+				}
+				row = append(row, cell)
+			}
+		case record.Raw != nil:
+			row = append(row, base64.StdEncoding.EncodeToString(record.Raw))
+		default:
+			row = append(row, string(record.Value))
+		}
+		return out.Write(row)
+	})
+	if err != nil {
+		return err
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func importCSV(vf valueFormat, r io.Reader, store func(exportRecord) error) error {
+	in := csv.NewReader(r)
+	header, err := in.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(header) < 2 || header[0] != "key" {
+		return fmt.Errorf("csv needs to start with a key column")
+	}
+	structType, isStruct := vf.structType()
+	for {
+		row, err := in.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		key, err := strconv.ParseUint(row[0], 10, 64)
+		if err != nil {
+			return err
+		}
+		record := exportRecord{Key: key}
+		switch {
+		case header[1] == "raw":
+			if record.Raw, err = base64.StdEncoding.DecodeString(row[1]); err != nil {
+				return err
+			}
+		case header[1] == "value":
+			record.Value = json.RawMessage(row[1])
+		case isStruct:
+			//fill in a fresh value field by field, then go through json like every other import.
+			value := reflect.New(structType)
+			for i, name := range header[1:] {
+				field := value.Elem().FieldByName(name)
+				if !field.IsValid() {
+					return fmt.Errorf("%v has no field %v", structType, name)
+				}
+				if !field.CanSet() {
+					//unexported, so it never gets a column on the way out either.
+					return fmt.Errorf("%v's field %v isn't exported, so it can't be imported", structType, name)
+				}
+				if err := parseCSVCell(row[i+1], field); err != nil {
+					return err
+				}
+			}
+			if record.Value, err = json.Marshal(value.Interface()); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("inksack %v needs a struct type to import csv columns into", vf.table)
+		}
+		if err := store(record); err != nil {
+			return err
+		}
+	}
+}
+
+// the text for one field in a csv. Simple values are written as they are, anything else as json.
+func csvCell(field reflect.Value) (string, error) {
+	switch field.Kind() {
+	case reflect.String:
+		return field.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(field.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(field.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(field.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(field.Float(), 'g', -1, 64), nil
+	}
+	data, err := json.Marshal(field.Interface())
+	return string(data), err
+}
+
+// reads one csv field back into place. The opposite of csvCell.
+func parseCSVCell(cell string, field reflect.Value) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(cell)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(cell)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(cell, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(cell, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(cell, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return json.Unmarshal([]byte(cell), field.Addr().Interface())
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package inkdb
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a type with a bit of everything, to make sure it all survives a trip out and back.
+type exportableObject struct {
+	Name  string
+	Count int
+	Ratio float64
+	Seen  bool
+	Tags  []string
+}
+
+func TestInkDBExportImport(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("source", &exportableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := ink.Append("source", &exportableObject{
+			Name:  "thing, \"quoted\"",
+			Count: i,
+			Ratio: float64(i) / 3,
+			Seen:  i%2 == 0,
+			Tags:  []string{"a", strings.Repeat("b", i)},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sourceVals, sourceKeys, err := ink.Get("source", SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []ExportFormat{JSONLines, CSV} {
+		exported := &bytes.Buffer{}
+		if err := ink.ExportTable("source", exported, format); err != nil {
+			t.Fatal(err)
+		}
+		//keep the keys in one table, and let the other hand out its own.
+		kept, renumbered := "kept", "renumbered"
+		folder := getInkTestFile()
+		ink2, err := NewInkDB(folder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ink2.NewTable(kept, &exportableObject{})
+		ink2.NewTable(renumbered, exportableObject{}, WithCodec(JSONCodec{}))
+		if err := ink2.ImportTable(kept, bytes.NewReader(exported.Bytes()), format, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := ink2.ImportTable(renumbered, bytes.NewReader(exported.Bytes()), format, false); err != nil {
+			t.Fatal(err)
+		}
+
+		vals, keys, err := ink2.Get(kept, SplotchKey{}, MaxSplotchKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, sourceKeys, keys)
+		assert.Equal(t, sourceVals, vals)
+		//those keys are all taken now, so they can't be placed again.
+		assert.Error(t, ink2.ImportTable(kept, bytes.NewReader(exported.Bytes()), format, true))
+		if err := ink2.Place(kept, SplotchKey{}.Plus(100), &exportableObject{Name: "placed"}); err != nil {
+			t.Fatal(err)
+		}
+
+		vals, keys, err = ink2.Get(renumbered, SplotchKey{}, MaxSplotchKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assert.Equal(t, len(sourceVals), len(vals)) {
+			for i := range vals {
+				assert.Equal(t, *sourceVals[i].(*exportableObject), vals[i])
+			}
+		}
+	}
+}
+
+func TestInkDBExportWithoutType(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ink.NewTable("gob", &exportableObject{})
+	ink.NewTable("json", &exportableObject{}, WithCodec(JSONCodec{}), WithCompression(GzipCompression))
+	for i := 0; i < 5; i++ {
+		ink.Append("gob", &exportableObject{Count: i})
+		ink.Append("json", &exportableObject{Count: i})
+	}
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//a fresh handle, that's never been told the types.
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported := &bytes.Buffer{}
+	if err := ink2.ExportTable("json", exported, JSONLines); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, strings.HasPrefix(exported.String(), `{"key":1,"value":{"Name":"","Count":0,`), exported.String())
+	//exporting reads through the table without keeping it all in memory afterwards.
+	stats, err := ink2.Stats("json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, stats.LoadedSplotches)
+
+	exported.Reset()
+	if err := ink2.ExportTable("gob", exported, CSV); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, strings.HasPrefix(exported.String(), "key,raw\n"))
+	//raw values can go back into a table with the same codec, and still decode.
+	ink2.NewTable("copy", nil)
+	if err := ink2.ImportTable("copy", exported, CSV, false); err != nil {
+		t.Fatal(err)
+	}
+	ink2.NewTable("copy", &exportableObject{})
+	vals, _, err := ink2.Get("copy", SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Equal(t, 5, len(vals)) {
+		assert.Equal(t, &exportableObject{Count: 4}, vals[4])
+	}
+}
+
+type privateFieldObject struct {
+	Name   string
+	secret string
+}
+
+func TestInkDBImportUnexportedField(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink.Close()
+	if err := ink.NewTable("table", &privateFieldObject{}); err != nil {
+		t.Fatal(err)
+	}
+	//a column for a field that couldn't have been exported is an error, not a panic.
+	assert.Error(t, ink.ImportTable("table", strings.NewReader("key,Name,secret\n1,a,b\n"), CSV, false))
+	assert.Error(t, ink.ImportTable("table", strings.NewReader("key,Missing\n1,a\n"), CSV, false))
+	if err := ink.ImportTable("table", strings.NewReader("key,Name\n1,a\n"), CSV, false); err != nil {
+		t.Fatal(err)
+	}
+	vals, _, err := ink.Get("table", SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []any{&privateFieldObject{Name: "a"}}, vals)
+}
@@ -1,33 +1,71 @@
 package inkdb
 
 import (
-	"bytes"
-	"encoding/gob"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path"
+	"strings"
+	"sync"
+	"time"
 
-	"go.uber.org/zap/buffer"
+	"go.uber.org/zap"
 )
 
 //lets crack out a main db layer
 
 // this is the top layer called.
 type InkDB struct {
-	fileStartPoint string
-	inkSacks       map[string]*inkSack //map[tableName]->sacks
-	inkColors      map[string]any
+	mu              sync.RWMutex //guards the maps of inksacks and their types. Each inksack has its own lock for its contents.
+	commitMu        sync.Mutex   //held while anything is being written to disc, so a snapshot sees a single point in time.
+	fileStartPoint  string
+	inkSacks        map[string]*inkSack //map[tableName]->sacks
+	inkColors       map[string]any
+	quotaBytes      int64      //the most bytes of values the whole database can hold. No limit if 0
+	quotaMu         sync.Mutex //guards reservedBytes, and makes checking the quota and reserving from it one step
+	reservedBytes   int64      //bytes set aside for appends that have passed the quota check, but haven't finished yet
+	minFreeSpace    int64      //free space to always leave on each drive
+	metrics         MetricsRegistry
+	logger          *zap.Logger
+	flusher         *flusher     //commits in the background, if auto commit is on
+	maintenance     *maintenance //enforces retention in the background, if it's on
+	scanParallelism int          //how many splotches GetParallel works on at once. One per CPU if 0
+	lockFile        *os.File     //held open (and locked) until Close
+	closed          bool
+	closing         bool //set while Close does its last commit, so no new tables get made that it would miss
+	readOnly        bool //opened with OpenReadOnly
 }
 
-func NewInkDB(storing string) (*InkDB, error) {
+func NewInkDB(storing string, opts ...DBOption) (*InkDB, error) {
 	idb := &InkDB{
 		fileStartPoint: storing,
 		inkSacks:       map[string]*inkSack{},
 		inkColors:      map[string]any{},
+		logger:         zap.NewNop(),
 	}
+	for _, opt := range opts {
+		opt(idb)
+	}
+	lock, err := lockDatabase(storing)
+	if err != nil {
+		idb.logger.Error("couldn't lock database", zap.String("location", storing), zap.Error(err))
+		return nil, err
+	}
+	idb.lockFile = lock
 	if err := idb.loadTables(); err != nil {
+		idb.logger.Error("couldn't open database", zap.String("location", storing), zap.Error(err))
+		unlockFile(lock)
+		lock.Close()
 		return nil, err
 	}
+	idb.logger.Info("opened database", zap.String("location", storing), zap.Int("tables", len(idb.inkSacks)))
+	if idb.flusher != nil {
+		idb.flusher.start(idb)
+	}
+	if idb.maintenance != nil {
+		idb.maintenance.start(idb)
+	}
 	//what to work on.
 	//find any files associated to itself.
 	//be able to add tables
@@ -46,11 +84,12 @@ func NewInkDB(storing string) (*InkDB, error) {
 // loads the minimal amount based on the files found around itself
 func (ink *InkDB) loadTables() error {
 	if _, err := os.Stat(path.Join(ink.fileStartPoint, "inksacks")); err != nil {
-		//no folder found there
-		if err = os.Mkdir(ink.fileStartPoint, 0777); err != nil {
+		if ink.readOnly {
+			//nothing to read, and it can't be made.
 			return err
 		}
-		if err = os.Mkdir(path.Join(ink.fileStartPoint, "inksacks"), 0777); err != nil {
+		//no folder found there
+		if err = os.MkdirAll(path.Join(ink.fileStartPoint, "inksacks"), 0777); err != nil {
 			return err
 		}
 	} else {
@@ -58,64 +97,268 @@ func (ink *InkDB) loadTables() error {
 		files, _ := os.ReadDir(path.Join(ink.fileStartPoint, "/inksacks/"))
 		ink.inkSacks = map[string]*inkSack{}
 		for _, filePath := range files {
-			sack, err := NewInkSack(filePath.Name())
+			if strings.HasPrefix(filePath.Name(), ".") {
+				//not a table. Most likely what's left of one that was being dropped.
+				if strings.HasPrefix(filePath.Name(), ".dropping-") && !ink.readOnly {
+					ink.logger.Info("removing what was left of a dropped table", zap.String("folder", filePath.Name()))
+					os.RemoveAll(path.Join(ink.fileStartPoint, "inksacks", filePath.Name()))
+				}
+				continue
+			}
+			sack, err := ink.openSack(filePath.Name())
 			if err != nil {
+				ink.logger.Error("couldn't load table", zap.String("table", filePath.Name()), zap.Error(err))
 				return err
 			}
+			ink.logger.Debug("loaded table", zap.String("table", filePath.Name()), zap.Int("splotches", len(sack.inkSplotches)))
 			ink.inkSacks[filePath.Name()] = sack
 		}
 	}
 	return nil
 }
-func (ink *InkDB) NewTable(name string, of any) error {
-	if ink.inkSacks[name] != nil {
-		return fmt.Errorf("inksack already exists")
-	}
-	newSack, err := NewInkSack(path.Join(ink.fileStartPoint, "/inksacks/", name))
 
+// opens (or creates) the sack for the table called name, hooked up to the database's settings.
+func (ink *InkDB) openSack(name string, opts ...TableOption) (*inkSack, error) {
+	return openInkSack(&inkSack{
+		localFilesLocation: path.Join(ink.fileStartPoint, "inksacks", name),
+		minFreeSpace:       ink.minFreeSpace,
+		metrics:            newTableMetrics(ink.metrics, name),
+		logger:             ink.tableLogger(name),
+		readOnly:           ink.readOnly,
+	}, opts...)
+}
+
+// make a new table, storing items like of. Any options are saved with the table.
+// calling this for a table that was loaded from disc just hands it the type to decode into, along with any changes to its options.
+// Once it holds records, its codec, compression, key generator and ID extractor are fixed, and changing them returns ErrTableNotEmpty.
+func (ink *InkDB) NewTable(name string, of any, opts ...TableOption) error {
+	if err := validTableName(name); err != nil {
+		return err
+	}
+	if ink.readOnly {
+		//all a reader can do is say what type a table's values are, for a table the writer has already made.
+		if len(opts) != 0 {
+			return ErrReadOnly
+		}
+		if _, err := ink.followTable(name); errors.Is(err, ErrNoTable) {
+			return fmt.Errorf("%w, and there's no table called %v to read", ErrReadOnly, name)
+		} else if err != nil {
+			return err
+		}
+	}
+	ink.mu.Lock()
+	defer ink.mu.Unlock()
+	if ink.closed || ink.closing {
+		return ErrClosed
+	}
+	if ink.readOnly && ink.inkSacks[name] == nil {
+		//dropped by the writer since it was found.
+		return fmt.Errorf("%w under %v", ErrNoTable, name)
+	}
+	if sack := ink.inkSacks[name]; sack != nil {
+		if ink.inkColors[name] != nil {
+			return fmt.Errorf("inksack already exists")
+		}
+		if len(opts) != 0 {
+			sack.mu.Lock()
+			defer sack.mu.Unlock()
+			if err := sack.setOptions(opts...); err != nil {
+				return err
+			}
+		}
+		ink.inkColors[name] = of
+		return nil
+	}
+	newSack, err := ink.openSack(name, opts...)
 	if err != nil {
 		return err
 	}
+	ink.logger.Info("created table", zap.String("table", name))
 	ink.inkSacks[name] = newSack
 	ink.inkColors[name] = of
 	return nil
 }
 
+// finds the inksack stored under name.
+func (ink *InkDB) getSack(name string) (*inkSack, error) {
+	if ink.readOnly {
+		return ink.followTable(name)
+	}
+	ink.mu.RLock()
+	defer ink.mu.RUnlock()
+	if ink.closed {
+		return nil, ErrClosed
+	}
+	if ink.inkSacks[name] == nil {
+		return nil, fmt.Errorf("%w under %v", ErrNoTable, name)
+	}
+	return ink.inkSacks[name], nil
+}
+
+// finds the inksack stored under name, along with how its values are encoded.
+func (ink *InkDB) getSackAndFormat(name string) (*inkSack, valueFormat, error) {
+	if ink.readOnly {
+		if _, err := ink.followTable(name); err != nil {
+			return nil, valueFormat{}, err
+		}
+	}
+	ink.mu.RLock()
+	defer ink.mu.RUnlock()
+	if ink.closed {
+		return nil, valueFormat{}, ErrClosed
+	}
+	sack := ink.inkSacks[name]
+	if sack == nil {
+		return nil, valueFormat{}, fmt.Errorf("%w under %v", ErrNoTable, name)
+	}
+	if err := sack.lock(); err != nil {
+		return nil, valueFormat{}, err
+	}
+	defer sack.mu.Unlock()
+	codec, err := lookupCodec(sack.metadata.Codec)
+	if err != nil {
+		return nil, valueFormat{}, err
+	}
+	return sack, valueFormat{
+		table:       name,
+		codec:       codec,
+		compression: sack.metadata.Compression,
+		prototype:   ink.inkColors[name],
+	}, nil
+}
+
+// change when the given inksack's splotches roll over. The policy is saved along with the rest of the inksack's data.
+func (ink *InkDB) SetRollover(inksack string, policy RolloverPolicy) error {
+	if err := ink.checkWritable(); err != nil {
+		return err
+	}
+	sack, err := ink.getSack(inksack)
+	if err != nil {
+		return err
+	}
+	if err := sack.lock(); err != nil {
+		return err
+	}
+	defer sack.mu.Unlock()
+	return sack.SetRollover(policy)
+}
+
 // automatically generate a key, and append the item to the given inksack
 func (ink *InkDB) Append(inksack string, item any) error {
-	if ink.inkSacks[inksack] == nil {
-		return fmt.Errorf("no inksack(table) found under %v", inksack)
+	return ink.AppendCtx(context.Background(), inksack, item)
+}
+
+// the same as Append, but gives up without storing anything if ctx is done before the item is added.
+func (ink *InkDB) AppendCtx(ctx context.Context, inksack string, item any) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := ink.checkWritable(); err != nil {
+		return err
+	}
+	sack, format, err := ink.getSackAndFormat(inksack)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	defer func() {
+		sack.metrics.record(appendMetric, start, err)
+	}()
+	data, err := format.encode(item)
+	if err != nil {
+		return err
+	}
+	//encoding a large item can take a while, so check again before it's stored.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ink.appendStored(sack, data)
+}
+
+// appends already encoded data to the sack, under a new key.
+func (ink *InkDB) appendStored(sack *inkSack, data []byte) error {
+	release, err := ink.reserveQuota(len(data))
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err := sack.lock(); err != nil {
+		return err
+	}
+	defer sack.mu.Unlock()
+	if err := sack.AutoAppend(data); err != nil {
+		return err
+	}
+	ink.flusher.added(1, int64(len(data)))
+	return nil
+}
+
+// append the item to the given inksack under a specific key. The key has to be larger than any already in the inksack.
+func (ink *InkDB) Place(inksack string, key SplotchKey, item any) error {
+	if err := ink.checkWritable(); err != nil {
+		return err
 	}
-	buffer := buffer.NewPool().Get()
-	enc := gob.NewEncoder(buffer)
-	if err := enc.Encode(item); err != nil {
+	sack, format, err := ink.getSackAndFormat(inksack)
+	if err != nil {
+		return err
+	}
+	data, err := format.encode(item)
+	if err != nil {
 		return err
 	}
-	return ink.inkSacks[inksack].AutoAppend(buffer.Bytes())
+	return ink.placeStored(sack, storedItem{Key: key, Value: data})
+}
+
+// puts already encoded data into the sack under its own key.
+func (ink *InkDB) placeStored(sack *inkSack, item storedItem) error {
+	release, err := ink.reserveQuota(len(item.Value))
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err := sack.lock(); err != nil {
+		return err
+	}
+	defer sack.mu.Unlock()
+	if err := sack.Append(item); err != nil {
+		return err
+	}
+	ink.flusher.added(1, int64(len(item.Value)))
+	return nil
 }
 
 // get from <inksack> with values <from>, <to>
 func (ink *InkDB) Get(inksack string, from, to SplotchKey) ([]any, []SplotchKey, error) {
-	ans, err := ink.GetStored(inksack, from, to)
+	return ink.GetCtx(context.Background(), inksack, from, to)
+}
+
+// the same as Get, but gives up between splotches and between records once ctx is done, returning ctx.Err().
+func (ink *InkDB) GetCtx(ctx context.Context, inksack string, from, to SplotchKey) (_ []any, _ []SplotchKey, err error) {
+	sack, format, err := ink.getSackAndFormat(inksack)
 	if err != nil {
 		return nil, nil, err
 	}
+	start := time.Now()
+	defer func() {
+		sack.metrics.record(getMetric, start, err)
+	}()
+	ans, err := ink.getStoredCtx(ctx, sack, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	sack.metrics.add(getRecordsMetric, float64(len(ans)))
 	outVals := make([]any, len(ans))
 	keys := make([]SplotchKey, len(ans))
 	for i, val := range ans {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
 		keys[i] = val.Key
-		//handle the decoding.
-		buffer := bytes.NewBuffer(val.Value)
-		dec := gob.NewDecoder(buffer)
-		var value = ink.inkColors[inksack]
-
-		if err := dec.Decode(value); err != nil {
+		value, err := format.decode(val.Value)
+		if err != nil {
 			return nil, nil, err
 		}
-		//I'm pretty sure this will make sure to copy the value at a low level.
-		tmpArr := make([]any, 1)
-		copy(tmpArr, []any{value})
-		outVals[i] = tmpArr[0]
+		outVals[i] = value
 	}
 
 	return outVals, keys, nil
@@ -123,27 +366,153 @@ func (ink *InkDB) Get(inksack string, from, to SplotchKey) ([]any, []SplotchKey,
 
 // get the stored items from an inksack, from <from>, to <to>. Returns any error encountered.
 func (ink *InkDB) GetStored(inksack string, from, to SplotchKey) ([]storedItem, error) {
-	if ink.inkSacks[inksack] == nil {
-		return nil, fmt.Errorf("no inksack(table) found under %v", inksack)
-	}
-	ans, err := ink.inkSacks[inksack].GetAll(from, to)
+	sack, err := ink.getSack(inksack)
 	if err != nil {
 		return nil, err
 	}
-	return ans, nil
+	return ink.getStoredCtx(context.Background(), sack, from, to)
+}
+
+func (ink *InkDB) getStoredCtx(ctx context.Context, sack *inkSack, from, to SplotchKey) ([]storedItem, error) {
+	if err := sack.lock(); err != nil {
+		return nil, err
+	}
+	defer sack.mu.Unlock()
+	return sack.getAllCtx(ctx, from, to)
+}
+
+// calls fn with each item in the inksack from <from> to <to>, in key order. Only one splotch's worth of items is
+// held at a time, and the inksack isn't locked while fn runs. If fn returns an error, the scan stops and hands it back.
+func (ink *InkDB) Scan(inksack string, from, to SplotchKey, fn func(key SplotchKey, value any) error) error {
+	return ink.ScanCtx(context.Background(), inksack, from, to, fn)
+}
+
+// the same as Scan, but stops between splotches and between records once ctx is done, returning ctx.Err().
+func (ink *InkDB) ScanCtx(ctx context.Context, inksack string, from, to SplotchKey, fn func(key SplotchKey, value any) error) error {
+	sack, format, err := ink.getSackAndFormat(inksack)
+	if err != nil {
+		return err
+	}
+	return sack.scanStored(ctx, from, to, sack.splotchGetAll, func(item storedItem) error {
+		value, err := format.decode(item.Value)
+		if err != nil {
+			return err
+		}
+		return fn(item.Key, value)
+	})
+}
+
+// hands every stored item from <from> to <to> to fn, still encoded, a splotch's worth at a time. Stops at the first error.
+// read gets each splotch's items, either splotchGetAll or peekSplotch, depending on whether they should stay loaded.
+func (is *inkSack) scanStored(ctx context.Context, from, to SplotchKey, read func(i int, from, to SplotchKey) ([]storedItem, error),
+	fn func(item storedItem) error) error {
+	//the scan carries on from a key rather than a splotch, since the splotches can change underneath it between reads.
+	position := from
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := is.lock(); err != nil {
+			return err
+		}
+		i := is.splotchHolding(position)
+		if i == -1 {
+			is.mu.Unlock()
+			return nil
+		}
+		largest := is.inkSplotches[i].headings.LargestKey
+		items, err := read(i, position, to)
+		if err == ErrSplotchRangeExceeded || (err == nil && len(items) == 0) {
+			//nothing else comes before to.
+			is.mu.Unlock()
+			return nil
+		}
+		items = is.hideTombstoned(items)
+		is.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		if largest.GreaterOrEqual(to) {
+			return nil
+		}
+		position = largest.NextKey()
+	}
+}
+
+// all of the inksacks, so they can be worked through without holding onto the lock for the map.
+func (ink *InkDB) allSacks() map[string]*inkSack {
+	ink.mu.RLock()
+	defer ink.mu.RUnlock()
+	sacks := make(map[string]*inkSack, len(ink.inkSacks))
+	for name, sack := range ink.inkSacks {
+		sacks[name] = sack
+	}
+	return sacks
 }
 
 // Commit is what actually saves the changes to the disc!
 func (ink *InkDB) Commit() error {
-	for _, inksack := range ink.inkSacks {
+	return ink.CommitCtx(context.Background())
+}
+
+// the same as Commit, but stops between tables once ctx is done, returning ctx.Err(). Tables committed before then stay
+// committed, and the rest keep their changes for the next commit. A table that has started committing always finishes,
+// so its splotches, mirrors and manifest never disagree with each other.
+func (ink *InkDB) CommitCtx(ctx context.Context) error {
+	if err := ink.checkWritable(); err != nil {
+		return err
+	}
+	return ink.commitCtx(ctx)
+}
+
+// commits every table, even once the database is closed. Close needs this for its final commit.
+func (ink *InkDB) commitCtx(ctx context.Context) error {
+	ink.commitMu.Lock()
+	defer ink.commitMu.Unlock()
+	for _, inksack := range ink.allSacks() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		start := time.Now()
+		inksack.mu.Lock()
 		err := inksack.Commit()
+		inksack.metrics.record(commitMetric, start, err)
+		inksack.mu.Unlock()
 		if err != nil {
+			inksack.logger.Error("commit failed", zap.Error(err))
 			return err
 		}
 	}
 	return nil
 }
 
+// checks every mirrored copy of the given inksack's splotches, rewriting any bad ones from a good copy.
+func (ink *InkDB) Scrub(inksack string) (ScrubReport, error) {
+	if err := ink.checkWritable(); err != nil {
+		return ScrubReport{}, err
+	}
+	sack, err := ink.getSack(inksack)
+	if err != nil {
+		return ScrubReport{}, err
+	}
+	//scrubbing rewrites files, so it can't happen part way through a commit (or snapshot).
+	ink.commitMu.Lock()
+	defer ink.commitMu.Unlock()
+	if err := sack.lock(); err != nil {
+		return ScrubReport{}, err
+	}
+	defer sack.mu.Unlock()
+	return sack.Scrub()
+}
+
 // this is the bottom most layer. The item that is actually written to disc.
 type storedItem struct {
 	Key   SplotchKey
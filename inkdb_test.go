@@ -1,6 +1,7 @@
 package inkdb
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
@@ -87,6 +88,246 @@ func TestInkDBAlreadyFilled(t *testing.T) {
 	}
 }
 
+func TestInkDBContext(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("table", &testableObject{}, WithRollover(RolloverPolicy{MaxRows: 3})); err != nil {
+		t.Fatal(err)
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, ink.AppendCtx(cancelled, "table", generateTestableObject(0)), context.Canceled)
+	for i := 0; i < 10; i++ {
+		if err := ink.AppendCtx(context.Background(), "table", generateTestableObject(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//the cancelled append shouldn't have left anything behind.
+	found, _, err := ink.Get("table", SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, found, 10)
+	_, _, err = ink.GetCtx(cancelled, "table", SplotchKey{}, MaxSplotchKey)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	//nothing gets committed once cancelled, but a later commit still has everything.
+	assert.ErrorIs(t, ink.CommitCtx(cancelled), context.Canceled)
+	stats, _ := ink.Stats("table")
+	assert.Equal(t, 10, stats.UncommittedRecords)
+	if err := ink.CommitCtx(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	//scans see every item in order, across splotches.
+	keys := []SplotchKey{}
+	err = ink.Scan("table", KeyFromUint64(2), KeyFromUint64(8), func(key SplotchKey, value any) error {
+		keys = append(keys, key)
+		assert.Equal(t, generateTestableObject(int(key.Uint64())-1), value)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, keys, 7)
+	assert.Equal(t, KeyFromUint64(2), keys[0])
+	assert.Equal(t, KeyFromUint64(8), keys[6])
+
+	//cancelling part way stops the scan at the next record.
+	ctx, cancel := context.WithCancel(context.Background())
+	seen := 0
+	err = ink.ScanCtx(ctx, "table", SplotchKey{}, MaxSplotchKey, func(key SplotchKey, value any) error {
+		seen++
+		if seen == 4 {
+			cancel()
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 4, seen)
+}
+
+func TestInkDBTableOptions(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tableName := "options"
+	if err := ink.NewTable(tableName, &testableObject{},
+		WithCodec(JSONCodec{}),
+		WithCompression(GzipCompression),
+		WithKeyGenerator(TimestampKeys{}),
+		WithRollover(RolloverPolicy{MaxRows: 3}),
+		WithDurability(DurabilitySync),
+		WithFileModes(0700, 0600),
+	); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := ink.Append(tableName, generateTestableObject(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//open it all again, the options should come back without being given.
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the values already stored couldn't be read any other way.
+	assert.ErrorIs(t, ink2.NewTable(tableName, &testableObject{}, WithCompression(NoCompression)), ErrTableNotEmpty)
+	assert.ErrorIs(t, ink2.NewTable(tableName, &testableObject{}, WithCodec(GobCodec{})), ErrTableNotEmpty)
+	//but anything else can change.
+	if err := ink2.NewTable(tableName, &testableObject{}, WithCodec(JSONCodec{}), WithDurability(DurabilityBuffered)); err != nil {
+		t.Fatal(err)
+	}
+	sack := ink2.inkSacks[tableName]
+	assert.Equal(t, DurabilityBuffered, sack.metadata.Durability)
+	assert.Equal(t, "json", sack.metadata.Codec)
+	assert.Equal(t, GzipCompression, sack.metadata.Compression)
+	assert.Equal(t, "timestamp", sack.metadata.KeyGenerator)
+	assert.Equal(t, 4, len(sack.inkSplotches))
+	info, err := os.Stat(sack.inkSplotches[0].fileLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	vals, keys, err := ink2.Get(tableName, SplotchKey{}, SplotchKey{255, 255, 255, 255, 255, 255, 255, 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Equal(t, 10, len(vals)) {
+		for i := range vals {
+			assert.Equal(t, generateTestableObject(i), vals[i].(*testableObject))
+		}
+		//timestamp keys should be well past anything a counter would have made.
+		assert.True(t, keys[0].GreaterThan(SplotchKey{}.Plus(1<<32)))
+	}
+	//the table already has its type now.
+	assert.Error(t, ink2.NewTable(tableName, &testableObject{}))
+
+	//a codec has to be registered before a table can use it.
+	assert.Error(t, ink2.NewTable("unregistered", &testableObject{}, WithCodec(unregisteredCodec{})))
+	RegisterCodec(unregisteredCodec{})
+	assert.NoError(t, ink2.NewTable("unregistered", &testableObject{}, WithCodec(unregisteredCodec{})))
+	ink2.Close()
+}
+
+func TestInkDBReopenOptions(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Append("table", generateTestableObject(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//the splotch already there has to pick the new options up too, not just the ones made from now on.
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink2.Close()
+	if err := ink2.NewTable("table", &testableObject{},
+		WithRollover(RolloverPolicy{MaxRows: 1}),
+		WithDurability(DurabilitySync),
+		WithFileModes(0700, 0600),
+	); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 4; i++ {
+		if err := ink2.Append("table", generateTestableObject(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ink2.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	sack := ink2.inkSacks["table"]
+	assert.Equal(t, 4, len(sack.inkSplotches))
+	for _, splotch := range sack.inkSplotches {
+		assert.Equal(t, RolloverPolicy{MaxRows: 1}, splotch.rollover)
+		assert.Equal(t, os.FileMode(0600), splotch.fileMode)
+		assert.True(t, splotch.syncOnSave)
+	}
+	info, err := os.Stat(sack.inkSplotches[3].fileLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	vals, _, err := ink2.Get("table", SplotchKey{}, SplotchKey{255, 255, 255, 255, 255, 255, 255, 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 4, len(vals))
+}
+
+func TestInkDBReopenDataDirs(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("table", &testableObject{}, WithRollover(RolloverPolicy{MaxRows: 2})); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Append("table", generateTestableObject(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//a data dir that's new on a reopen has to have its folder made before anything rolls over into it.
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink2.Close()
+	drive := path.Join(folder, "newDrive")
+	if err := ink2.NewTable("table", &testableObject{}, WithDataDirs(RoundRobin, drive)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 7; i++ {
+		if err := ink2.Append("table", generateTestableObject(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ink2.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	files, err := os.ReadDir(path.Join(drive, "table", "splotches"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, len(files))
+	vals, _, err := ink2.Get("table", SplotchKey{}, SplotchKey{255, 255, 255, 255, 255, 255, 255, 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 7, len(vals))
+}
+
+type unregisteredCodec struct{ JSONCodec }
+
+func (unregisteredCodec) Name() string { return "unregistered" }
+
 func BenchmarkInkDBCommit(b *testing.B) {
 	folder := getInkTestFile()
 	ink, err := NewInkDB(folder)
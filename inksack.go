@@ -1,42 +1,271 @@
 package inkdb
 
 import (
+	"context"
+	"encoding/gob"
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 // this is per clustering of splotches. EG, one per stored table.
+// an inkSack isn't safe to share between goroutines by itself. InkDB holds mu around everything it does with one.
 type inkSack struct {
+	mu sync.Mutex
+
 	//this items data will always be under /inkSackData
 	//the splotch data will be under /splotches/n.txt
 	localFilesLocation string //where is this storing it's data.
 	inkSplotches       []*inkSplotch
 	largestKey         SplotchKey
+	metadata           sackMetadata
+	manifest           sackManifest
+	manifestChanged    bool                 //if the manifest has changed since it was last saved
+	usedBytes          int64                //bytes of values held, committed or not. Kept up to date for the quota checks.
+	minFreeSpace       int64                //free space to always leave on each drive
+	dropped            bool                 //set once the table is dropped, so anyone still holding onto it gets turned away
+	closed             bool                 //set once the database is closed
+	readOnly           bool                 //never writes anything, another process might be
+	tombstones         map[SplotchKey]int64 //when each tombstoned key was tombstoned
+	unsavedTombstones  []SplotchKey         //tombstones that haven't been added to the log yet
+	tombstonesRead     logStamp             //what the tombstone log looked like when it was last read
+	latest             *latestIndex         //the newest key for each ID, for latest value tables. Nil until it's first needed
+	partials           partialCache         //aggregations of closed splotches, kept for next time
+	metrics            *tableMetrics
+	logger             *zap.Logger //already carries the table's name
 }
 
-func NewInkSack(localFiles string) (*inkSack, error) {
-	is := &inkSack{
+// the settings an inkSack keeps for itself. Saved under /inkSackData so they survive a restart.
+type sackMetadata struct {
+	Rollover       RolloverPolicy
+	Codec          string //name of the registered codec to use
+	Compression    Compression
+	KeyGenerator   string //name of the registered key generator to use
+	Retention      RetentionPolicy
+	Durability     Durability
+	DirMode        os.FileMode
+	FileMode       os.FileMode
+	DataDirs       []string //where splotches can be placed. Just the sack's own folder if empty.
+	Placement      PlacementPolicy
+	HotSplotches   int           //how many splotches stay on the hot directory, for HotColdTiers
+	Mirrors        []string      //directories that each keep a full copy of every splotch
+	QuotaBytes     int64         //the most bytes of values this sack can hold. No limit if 0
+	ArchiveDirs    []string      //every directory splotches have been archived to
+	TombstoneGrace time.Duration //how long tombstoned records stay on the disc before Compact drops them
+	LatestBy       string        //name of the registered ID extractor, for tables that keep the latest value for each ID
+}
+
+// loads (or creates) the inkSack living at localFiles. Any options given are applied over the saved settings, and saved.
+func NewInkSack(localFiles string, opts ...TableOption) (*inkSack, error) {
+	return openInkSack(&inkSack{
 		localFilesLocation: localFiles,
+	}, opts...)
+}
+
+// loads (or creates) a sack that has already had its location, and anything it's handed from the database, filled in.
+func openInkSack(is *inkSack, opts ...TableOption) (*inkSack, error) {
+	if is.metrics == nil {
+		is.metrics = newTableMetrics(nil, path.Base(is.localFilesLocation))
+	}
+	if is.logger == nil {
+		is.logger = zap.NewNop()
+	}
+	//the saved settings are needed before anything is created, so the folders get the right permissions.
+	if err := is.loadMetadata(); err != nil {
+		return nil, err
+	}
+	saved := is.metadata
+	for _, opt := range opts {
+		opt(&is.metadata)
+	}
+	if len(opts) != 0 {
+		if err := is.metadata.checkRegistered(); err != nil {
+			return nil, err
+		}
+	}
+	if is.readOnly {
+		return is, is.LoadChildrenFromDisc()
 	}
 	//first, we should setup the file directory system it needs, if it isn't already.
-	//I think we'll need to save some states within this, but i'm not actually certain anymore
 	//once we have the file structure setup, we should load any data stored already for this inkSack, then load the children splotches
 	if err := is.setupFolderStructure(); err != nil {
 		return nil, err
 	}
-	return is, is.LoadChildrenFromDisc()
+	if err := is.LoadChildrenFromDisc(); err != nil {
+		return nil, err
+	}
+	//the options are only saved once it's known they don't change how what's already there is stored.
+	if len(opts) != 0 {
+		if err := is.checkFormatChange(saved); err != nil {
+			return nil, err
+		}
+		if err := is.saveMetadata(); err != nil {
+			return nil, err
+		}
+	}
+	return is, nil
+}
+
+// changes the options of a sack that's already loaded, and saves them. Nothing changes if any of them can't be used.
+func (is *inkSack) setOptions(opts ...TableOption) error {
+	saved := is.metadata
+	for _, opt := range opts {
+		opt(&is.metadata)
+	}
+	if err := is.metadata.checkRegistered(); err != nil {
+		is.metadata = saved
+		return err
+	}
+	if err := is.checkFormatChange(saved); err != nil {
+		is.metadata = saved
+		return err
+	}
+	//any new data directories need their folders before a splotch can be put in one.
+	if err := is.setupFolderStructure(); err != nil {
+		is.metadata = saved
+		return err
+	}
+	if err := is.fillMirrors(addedDirs(saved.Mirrors, is.metadata.Mirrors)); err != nil {
+		is.metadata = saved
+		return err
+	}
+	if err := is.saveMetadata(); err != nil {
+		return err
+	}
+	//the splotches already loaded were set up with the old options.
+	for _, splotch := range is.inkSplotches {
+		splotch.rollover = is.metadata.Rollover
+		splotch.fileMode = is.metadata.fileMode()
+		splotch.syncOnSave = is.metadata.Durability == DurabilitySync
+	}
+	return nil
+}
+
+// returns ErrTableNotEmpty if the options have changed how values are stored since saved, and there are already values
+// stored the old way.
+func (is *inkSack) checkFormatChange(saved sackMetadata) error {
+	if is.metadata.storageFormat() == saved.storageFormat() {
+		return nil
+	}
+	for _, splotch := range is.inkSplotches {
+		if splotch.headings.LinesStored != 0 {
+			return fmt.Errorf("%w, so %v's codec, compression, key generator and ID extractor can't change", ErrTableNotEmpty, path.Base(is.localFilesLocation))
+		}
+	}
+	return nil
+}
+
+// takes the sack's lock, as long as it hasn't been dropped in the meantime.
+func (is *inkSack) lock() error {
+	is.mu.Lock()
+	if is.dropped {
+		is.mu.Unlock()
+		return fmt.Errorf("%w, it was dropped", ErrNoTable)
+	}
+	if is.closed {
+		is.mu.Unlock()
+		return ErrClosed
+	}
+	return nil
+}
+
+// where the inkSack's own settings are kept
+func (is *inkSack) metadataLocation() string {
+	return path.Join(is.localFilesLocation, "inkSackData")
+}
+
+// reads the saved settings, if there are any. A sack without any saved just keeps the defaults.
+func (is *inkSack) loadMetadata() error {
+	f, err := os.Open(is.metadataLocation())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	return gob.NewDecoder(f).Decode(&is.metadata)
+}
+
+// writes the settings to disc. Written to a temp file first, so a crash can't leave half of them behind.
+func (is *inkSack) saveMetadata() error {
+	tmpLocation := is.metadataLocation() + ".tmp"
+	f, err := os.OpenFile(tmpLocation, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, is.metadata.fileMode())
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(&is.metadata); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpLocation, is.metadataLocation())
+}
+
+// changes when this sack's splotches roll over to the next one, and saves it with the rest of the sack's settings.
+// only changes where new items end up, nothing already stored gets moved around.
+func (is *inkSack) SetRollover(policy RolloverPolicy) error {
+	is.metadata.Rollover = policy
+	for _, splotch := range is.inkSplotches {
+		splotch.rollover = policy
+	}
+	return is.saveMetadata()
+}
+
+// opens the splotch file for an entry in the manifest, with this sack's settings. It's an error if the file is gone
+// and there's no mirror to read it from instead.
+func (is *inkSack) newSplotch(entry manifestEntry) (*inkSplotch, error) {
+	if entry.archived() {
+		return is.stubSplotch(entry), nil
+	}
+	return is.openSplotch(entry, true)
+}
+
+// opens the splotch file for entry, creating it unless the manifest already lists it.
+func (is *inkSack) openSplotch(entry manifestEntry, listed bool) (*inkSplotch, error) {
+	readLocation := ""
+	if len(is.metadata.Mirrors) != 0 {
+		if location, _ := is.healthyCopy(entry); location != is.entryLocation(entry) {
+			is.logger.Warn("main copy of splotch is bad, reading from a mirror instead",
+				zap.String("splotch", entry.Name), zap.String("mirror", location))
+			readLocation = location
+		}
+	}
+	splotch := &inkSplotch{
+		fileLocation: is.entryLocation(entry),
+		readLocation: readLocation,
+		rollover:     is.metadata.Rollover,
+		fileMode:     is.metadata.fileMode(),
+		syncOnSave:   is.metadata.Durability == DurabilitySync,
+		metrics:      is.metrics,
+		logger:       is.logger.With(zap.String("splotch", entry.Name)),
+		readOnly:     is.readOnly,
+		listed:       listed,
+	}
+	if entry.hasChecksum() {
+		splotch.setChecksum(entry.Checksum)
+	}
+	return openInkSplotch(splotch)
 }
 
 // checks to see if the folders already exist, and if they don't, it generates the correct folders.
 func (is *inkSack) setupFolderStructure() error {
 	if _, err := os.Stat(is.localFilesLocation); err != nil {
 		//no folder found there
-		if err = os.Mkdir(is.localFilesLocation, 0777); err != nil {
+		if err = os.MkdirAll(path.Join(is.localFilesLocation, "splotches"), is.metadata.dirMode()); err != nil {
 			return err
 		}
-		if err = os.Mkdir(path.Join(is.localFilesLocation, "splotches"), 0777); err != nil {
+	}
+	//any other data directories (or mirrors) might be new, even if the sack isn't.
+	for _, dir := range is.otherFolders() {
+		if err := os.MkdirAll(is.splotchFolder(dir), is.metadata.dirMode()); err != nil {
 			return err
 		}
 	}
@@ -50,25 +279,68 @@ func (is *inkSack) LoadChildrenFromDisc() error {
 		return err
 	}
 
-	//figure out what files are inkSplotch files, then load those to a partial state.
-	files, _ := os.ReadDir(path.Join(is.localFilesLocation, "/splotches/"))
-	is.inkSplotches = make([]*inkSplotch, len(files))
-	for i, filePath := range files {
-		splotch, err := NewInkSplotch(filePath.Name())
+	//the manifest says which files are inkSplotch files, and where they are. Then load those to a partial state.
+	manifest, found, err := is.loadManifest()
+	if err != nil {
+		return err
+	}
+	if !found {
+		//an older sack, everything will be in its own folder.
+		if manifest, err = is.legacyManifest(); err != nil {
+			return err
+		}
+		is.logger.Info("no manifest found, rebuilt one from the splotches folder", zap.Int("splotches", len(manifest.Splotches)))
+	}
+	if err := is.loadTombstones(); err != nil {
+		return err
+	}
+	is.manifest = manifest
+	is.inkSplotches = make([]*inkSplotch, len(manifest.Splotches))
+	for i, entry := range manifest.Splotches {
+		splotch, err := is.newSplotch(entry)
 		if err != nil {
 			return err
 		}
 		is.inkSplotches[i] = splotch
 	}
-	//they should be in order, but just in case.
-	sort.Slice(is.inkSplotches, func(i, j int) bool {
-		return is.inkSplotches[i].smallestKey.LessThan(is.inkSplotches[j].smallestKey)
+	//they should be in order, but just in case. Empty splotches don't have a smallest key yet, so they belong at the end.
+	order := make([]int, len(is.inkSplotches))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := is.inkSplotches[order[i]], is.inkSplotches[order[j]]
+		if b.headings.LinesStored == 0 {
+			return a.headings.LinesStored != 0
+		}
+		if a.headings.LinesStored == 0 {
+			return false
+		}
+		return a.smallestKey.LessThan(b.smallestKey)
 	})
+	splotches := make([]*inkSplotch, len(order))
+	entries := make([]manifestEntry, len(order))
+	for i, from := range order {
+		splotches[i] = is.inkSplotches[from]
+		entries[i] = manifest.Splotches[from]
+	}
+	is.inkSplotches = splotches
+	is.manifest.Splotches = entries
+	is.usedBytes = is.bytesStored()
+	if len(is.inkSplotches) != 0 {
+		is.largestKey = is.inkSplotches[len(is.inkSplotches)-1].headings.LargestKey
+	}
+	if !found && !is.readOnly {
+		return is.saveManifest()
+	}
 	return nil
 }
 
 // generates a key for the piece of data, and stores it automatically. More reliable than Append.
 func (is *inkSack) AutoAppend(data []byte) error {
+	if err := is.checkQuota(len(data)); err != nil {
+		return err
+	}
 	if len(is.inkSplotches) == 0 {
 		if err := is.addSplotch(); err != nil {
 			return err
@@ -80,34 +352,41 @@ func (is *inkSack) AutoAppend(data []byte) error {
 			return err
 		}
 	}
-	if err := is.inkSplotches[len(is.inkSplotches)-1].AutoAppend(data); err != nil {
+	generator, err := lookupKeyGenerator(is.metadata.KeyGenerator)
+	if err != nil {
 		return err
 	}
-	is.largestKey = is.inkSplotches[len(is.inkSplotches)-1].headings.LargestKey
+	last := is.inkSplotches[len(is.inkSplotches)-1]
+	if err := last.Append(storedItem{
+		Key:   generator.NextKey(last.headings.LargestKey),
+		Value: data,
+	}); err != nil {
+		return err
+	}
+	is.largestKey = last.headings.LargestKey
+	is.usedBytes += int64(len(data))
 	return nil
 }
 
 // adds data at given key. Less reliable option compared to AutoAppend!
+// everything is append only, so the key has to be larger than any already stored.
 func (is *inkSack) Append(data storedItem) error {
-	//check that the key is in an acceptable point.
-	//first, find the splotch it belongs to.
-	size := len(is.inkSplotches) - 1
-	start := 0
-	for {
-		splotch := is.inkSplotches[start+size]
-		min, max := splotch.GetEnds()
-		if data.Key.GreaterOrEqual(min) && (data.Key.LessOrEqual(max) || max.Equal(SplotchKey{})) {
-			//we found out spot
-			return splotch.Append(data)
-		}
-		if min.LessThan(data.Key) {
-			//this min is too high
-			size = size / 2
-		} else {
-			//this min is too low
-			start += size
+	if err := is.checkQuota(len(data.Value)); err != nil {
+		return err
+	}
+	//only the last splotch can take anything new, so there's no need to go looking for where it belongs.
+	if len(is.inkSplotches) == 0 || is.inkSplotches[len(is.inkSplotches)-1].IsFull() {
+		if err := is.addSplotch(); err != nil {
+			return err
 		}
 	}
+	last := is.inkSplotches[len(is.inkSplotches)-1]
+	if err := last.Append(data); err != nil {
+		return err
+	}
+	is.largestKey = last.headings.LargestKey
+	is.usedBytes += int64(len(data.Value))
+	return nil
 }
 
 // finds which splotch contains an element, based on the lessThan, and equal functions
@@ -139,8 +418,7 @@ func (is *inkSack) SearchForSplotch(lessThan, equal func(storedItem) bool) (*ink
 
 // add another splotch to follow the last one
 func (is *inkSack) addSplotch() error {
-	nextFileName := fmt.Sprintf("%v/splotches/s%#08x.txt", is.localFilesLocation, len(is.inkSplotches))
-	splotch, err := NewInkSplotch(nextFileName)
+	splotch, entry, err := is.createSplotch(&is.manifest)
 	if err != nil {
 		return err
 	}
@@ -149,24 +427,88 @@ func (is *inkSack) addSplotch() error {
 		splotch.headings.LargestKey = is.inkSplotches[len(is.inkSplotches)-1].headings.LargestKey
 	}
 	is.inkSplotches = append(is.inkSplotches, splotch)
+	is.manifest.Splotches = append(is.manifest.Splotches, entry)
+	//the new splotch has nothing committed yet, so the manifest can wait for the next Commit.
+	is.manifestChanged = true
 	return nil
 }
 
+// makes the file for a new, empty splotch, named by manifest. It's up to the caller to add it to the sack.
+func (is *inkSack) createSplotch(manifest *sackManifest) (*inkSplotch, manifestEntry, error) {
+	dataDir := is.pickDataDir()
+	if err := is.checkSpace(map[string]int64{is.splotchFolder(dataDir): splotchHeaderSize}); err != nil {
+		return nil, manifestEntry{}, err
+	}
+	entry := manifestEntry{
+		DataDir: dataDir,
+		Name:    manifest.nextName(),
+	}
+	splotch, err := is.openSplotch(entry, false)
+	if err != nil {
+		return nil, entry, err
+	}
+	is.logger.Debug("created splotch", zap.String("splotch", entry.Name), zap.String("dataDir", is.splotchFolder(dataDir)))
+	return splotch, entry, nil
+}
+
 // save any unsaved changes to the disc
 func (is *inkSack) Commit() error {
-	for _, splotch := range is.inkSplotches {
-		if err := splotch.SaveToFile(); err != nil {
+	changed := []int{}
+	needed := map[string]int64{}
+	for i, splotch := range is.inkSplotches {
+		if len(splotch.unsavedItems) == 0 {
+			//nothing new, so nothing to write.
+			continue
+		}
+		changed = append(changed, i)
+		needed[path.Dir(splotch.fileLocation)] += splotch.pendingBytes()
+		for _, mirror := range is.metadata.Mirrors {
+			needed[path.Dir(is.mirrorLocation(mirror, is.manifest.Splotches[i]))] += splotch.headings.DataEnd + splotch.pendingBytes()
+		}
+	}
+	//better to fail now, than part way through writing.
+	if err := is.checkSpace(needed); err != nil {
+		return err
+	}
+	for _, i := range changed {
+		if err := is.restoreFromMirror(i); err != nil {
+			return err
+		}
+		if err := is.inkSplotches[i].SaveToFile(); err != nil {
 			return err
 		}
 	}
-	return nil
+	if err := is.writeMirrors(changed); err != nil {
+		return err
+	}
+	if err := is.saveTombstones(); err != nil {
+		return err
+	}
+	if is.manifestChanged {
+		if err := is.saveManifest(); err != nil {
+			return err
+		}
+		is.manifestChanged = false
+	}
+	if len(changed) != 0 {
+		is.logger.Debug("committed table", zap.Int("splotches", len(changed)))
+	}
+	return is.moveColdSplotches()
 }
 
 // get all storedItems from<from>, to <to>. in chronological order
 func (is *inkSack) GetAll(from, to SplotchKey) ([]storedItem, error) {
+	return is.getAllCtx(context.Background(), from, to)
+}
+
+// the same as GetAll, but gives up between splotches once ctx is done.
+func (is *inkSack) getAllCtx(ctx context.Context, from, to SplotchKey) ([]storedItem, error) {
 	ans := []storedItem{}
-	for _, splotch := range is.inkSplotches {
-		returned, err := splotch.GetAll(from, to)
+	for i := range is.inkSplotches {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		returned, err := is.splotchGetAll(i, from, to)
 		if err != ErrSplotchRangeExceeded && err != nil {
 			return nil, err
 		} else if err == ErrSplotchRangeExceeded && len(ans) != 0 {
@@ -177,5 +519,46 @@ func (is *inkSack) GetAll(from, to SplotchKey) ([]storedItem, error) {
 			ans = append(ans, returned...)
 		}
 	}
-	return ans, nil
+	return is.hideTombstoned(ans), nil
+}
+
+// gets the items from <from> to <to> out of the i'th splotch. If its main copy can't be read, a mirror is used instead.
+func (is *inkSack) splotchGetAll(i int, from, to SplotchKey) ([]storedItem, error) {
+	splotch := is.inkSplotches[i]
+	returned, err := splotch.GetAll(from, to)
+	if err != ErrSplotchRangeExceeded && err != nil && is.fallBackToMirror(i) {
+		//the main copy couldn't be read, but a mirror can take its place.
+		is.logger.Warn("couldn't read splotch, reading from a mirror instead", zap.String("splotch", is.manifest.Splotches[i].Name),
+			zap.String("mirror", splotch.readLocation), zap.Error(err))
+		returned, err = splotch.GetAll(from, to)
+	}
+	return returned, err
+}
+
+// the same as splotchGetAll, but a splotch that isn't already in memory is loaded into a copy that's let go of afterwards,
+// rather than staying loaded. For reading through a whole table once.
+func (is *inkSack) peekSplotch(i int, from, to SplotchKey) ([]storedItem, error) {
+	splotch := is.inkSplotches[i]
+	if splotch.hasFullyLoaded || len(splotch.unsavedItems) != 0 {
+		return is.splotchGetAll(i, from, to)
+	}
+	peek := *splotch
+	returned, err := peek.GetAll(from, to)
+	if err != ErrSplotchRangeExceeded && err != nil && is.fallBackToMirror(i) {
+		is.logger.Warn("couldn't read splotch, reading from a mirror instead", zap.String("splotch", is.manifest.Splotches[i].Name),
+			zap.String("mirror", splotch.readLocation), zap.Error(err))
+		peek = *splotch
+		returned, err = peek.GetAll(from, to)
+	}
+	return returned, err
+}
+
+// the index of the first splotch with anything stored at or after key, or -1 if there isn't one.
+func (is *inkSack) splotchHolding(key SplotchKey) int {
+	for i, splotch := range is.inkSplotches {
+		if splotch.headings.LinesStored != 0 && splotch.headings.LargestKey.GreaterOrEqual(key) {
+			return i
+		}
+	}
+	return -1
 }
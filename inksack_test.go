@@ -5,6 +5,9 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 // tries to make sure that the test env is setup, and empty
@@ -46,3 +49,143 @@ func TestInkSack(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestInkSackRollover(t *testing.T) {
+	folder := getSackTestFolder()
+	MaxRowsPerSplotch = 100
+	is, err := NewInkSack(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//each value is 10 bytes, so every splotch should take 5 of them.
+	if err := is.SetRollover(RolloverPolicy{MaxBytes: 50}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := is.AutoAppend([]byte(fmt.Sprintf("%010v", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, 4, len(is.inkSplotches))
+	if err := is.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	//the policy should come back with the sack, and the next append should start a new splotch.
+	is2, err := NewInkSack(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, RolloverPolicy{MaxBytes: 50}, is2.metadata.Rollover)
+	if err := is2.AutoAppend([]byte(fmt.Sprintf("%010v", 20))); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 5, len(is2.inkSplotches))
+	items, err := is2.GetAll(SplotchKey{}, SplotchKey{}.Plus(21))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 21, len(items))
+}
+
+func TestInkSackRolloverByAge(t *testing.T) {
+	folder := getSackTestFolder()
+	MaxRowsPerSplotch = 100
+	is, err := NewInkSack(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := is.SetRollover(RolloverPolicy{MaxAge: 20 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := is.AutoAppend([]byte("early")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(30 * time.Millisecond)
+	if err := is.AutoAppend([]byte("late")); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(is.inkSplotches))
+}
+
+func TestInkSackDataDirs(t *testing.T) {
+	folder := getSackTestFolder()
+	dirs := []string{path.Join(folder, "driveA"), path.Join(folder, "driveB"), path.Join(folder, "driveC")}
+	for _, dir := range dirs {
+		os.RemoveAll(dir)
+	}
+	is, err := NewInkSack(folder, WithDataDirs(RoundRobin, dirs...), WithRollover(RolloverPolicy{MaxRows: 10}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 60; i++ {
+		if err := is.AutoAppend([]byte(fmt.Sprintf("%010v", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := is.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	//6 splotches, 2 on each drive
+	for _, dir := range dirs {
+		files, err := os.ReadDir(path.Join(dir, path.Base(folder), "splotches"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 2, len(files))
+	}
+
+	//after a restart, the manifest should find them all again.
+	is2, err := NewInkSack(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := is2.GetAll(SplotchKey{}, SplotchKey{}.Plus(60))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Equal(t, 60, len(items)) {
+		for i, item := range items {
+			assert.Equal(t, []byte(fmt.Sprintf("%010v", i)), item.Value)
+		}
+	}
+}
+
+func TestInkSackHotColdTiers(t *testing.T) {
+	folder := getSackTestFolder()
+	hot, cold := path.Join(folder, "hot"), path.Join(folder, "cold")
+	os.RemoveAll(hot)
+	os.RemoveAll(cold)
+	is, err := NewInkSack(folder,
+		WithDataDirs(HotColdTiers, hot, cold),
+		WithHotSplotches(2),
+		WithRollover(RolloverPolicy{MaxRows: 10}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 55; i++ {
+		if err := is.AutoAppend([]byte(fmt.Sprintf("%010v", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := is.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	hotFiles, _ := os.ReadDir(path.Join(hot, path.Base(folder), "splotches"))
+	coldFiles, _ := os.ReadDir(path.Join(cold, path.Base(folder), "splotches"))
+	assert.Equal(t, 2, len(hotFiles))
+	assert.Equal(t, 4, len(coldFiles))
+
+	is2, err := NewInkSack(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := is2.GetAll(SplotchKey{}, SplotchKey{}.Plus(55))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 55, len(items))
+}
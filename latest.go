@@ -0,0 +1,191 @@
+package inkdb
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// pulls the ID out of a (decoded) value, for tables that keep the latest value for each ID. See WithLatestBy.
+// a table only remembers which extractor it uses by name, so renaming one (or changing what IDs it gives) makes Latest
+// and Compact treat the old values as belonging to different things.
+type IDExtractor interface {
+	Name() string
+	ID(value any) (string, error)
+}
+
+// an IDExtractor made from a function.
+type idFunc struct {
+	name string
+	id   func(value any) (string, error)
+}
+
+func (f idFunc) Name() string                 { return f.name }
+func (f idFunc) ID(value any) (string, error) { return f.id(value) }
+
+// makes an IDExtractor, saved with tables under name, out of id.
+func NewIDExtractor(name string, id func(value any) (string, error)) IDExtractor {
+	return idFunc{name: name, id: id}
+}
+
+var (
+	idExtractorsMu sync.RWMutex
+	idExtractors   = map[string]IDExtractor{}
+)
+
+// makes an ID extractor available to tables by its name. Like codecs, it needs registering before WithLatestBy can use
+// it, and again after a restart.
+func RegisterIDExtractor(extractor IDExtractor) {
+	idExtractorsMu.Lock()
+	defer idExtractorsMu.Unlock()
+	idExtractors[extractor.Name()] = extractor
+}
+
+// finds the extractor saved under name.
+func lookupIDExtractor(name string) (IDExtractor, error) {
+	if name == "" {
+		return nil, fmt.Errorf("table doesn't keep latest values, it needs WithLatestBy")
+	}
+	idExtractorsMu.RLock()
+	extractor, ok := idExtractors[name]
+	idExtractorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no ID extractor registered under %v", name)
+	}
+	return extractor, nil
+}
+
+// treats the table as a log of changes to things, each with an ID pulled out of its value by extractor, which needs
+// RegisterIDExtractor first. Latest finds the newest value for an ID, and Compact drops any value that has a newer one with the same ID.
+func WithLatestBy(extractor IDExtractor) TableOption {
+	return func(md *sackMetadata) {
+		md.LatestBy = extractor.Name()
+	}
+}
+
+// the newest key for every ID in a latest value table. Built the first time it's needed, then kept up with whatever
+// has been appended since.
+type latestIndex struct {
+	keys    map[string]SplotchKey
+	upTo    SplotchKey //everything up to here has been looked at
+	started bool       //if anything has been looked at yet
+}
+
+// finds the newest value stored for id, and its key. Returns ErrNotFound if there isn't one, or the newest one has been tombstoned.
+func (ink *InkDB) Latest(table, id string) (any, SplotchKey, error) {
+	sack, format, err := ink.getSackAndFormat(table)
+	if err != nil {
+		return nil, SplotchKey{}, err
+	}
+	if err := sack.lock(); err != nil {
+		return nil, SplotchKey{}, err
+	}
+	defer sack.mu.Unlock()
+	//lets go of the lock while it decodes, so the first call on a big table doesn't hold everything else up.
+	if err := sack.catchUpLatest(format); err != nil {
+		return nil, SplotchKey{}, err
+	}
+	key, found := sack.latest.keys[id]
+	if !found {
+		return nil, SplotchKey{}, fmt.Errorf("%w for %v", ErrNotFound, id)
+	}
+	items, err := sack.GetAll(key, key)
+	if err != nil {
+		return nil, SplotchKey{}, err
+	}
+	if len(items) == 0 {
+		//tombstoned, or expired.
+		return nil, SplotchKey{}, fmt.Errorf("%w for %v", ErrNotFound, id)
+	}
+	value, err := format.decode(items[0].Value)
+	if err != nil {
+		return nil, SplotchKey{}, err
+	}
+	return value, key, nil
+}
+
+// brings the latest index up to date with everything in the sack, decoding whatever's been added since it last was.
+// tombstoned values still count, so tombstoning the newest value doesn't bring back an older one.
+// the sack's lock has to be held, and it still is once this returns. In between, it works through one splotch at a
+// time, only holding the lock while the splotch is read, and not while its values are decoded. Splotches that weren't
+// in memory already aren't kept there either, so the first call doesn't leave the whole table loaded.
+func (is *inkSack) catchUpLatest(format valueFormat) error {
+	extractor, err := lookupIDExtractor(is.metadata.LatestBy)
+	if err != nil {
+		return err
+	}
+	if is.latest == nil {
+		is.latest = &latestIndex{keys: map[string]SplotchKey{}}
+	}
+	for {
+		from := SplotchKey{}
+		if is.latest.started {
+			if is.latest.upTo.GreaterOrEqual(is.largestKey) {
+				return nil
+			}
+			from = is.latest.upTo.NextKey()
+		}
+		i := is.splotchHolding(from)
+		if i == -1 {
+			//nothing (else) has been stored.
+			is.latest.upTo = is.largestKey
+			is.latest.started = true
+			return nil
+		}
+		started, upTo := is.latest.started, is.inkSplotches[i].headings.LargestKey
+		items, err := is.peekSplotch(i, from, MaxSplotchKey)
+		if err != nil && err != ErrSplotchRangeExceeded {
+			return err
+		}
+
+		is.mu.Unlock()
+		ids, err := extractIDs(items, format, extractor)
+		is.mu.Lock()
+		if err != nil {
+			return err
+		}
+		if is.dropped {
+			return fmt.Errorf("%w, it was dropped", ErrNoTable)
+		}
+		if is.closed {
+			return ErrClosed
+		}
+		if is.latest.started != started || (started && is.latest.upTo.NextKey() != from) {
+			//someone else caught it up while it was unlocked, so this splotch has already been done.
+			continue
+		}
+		for n, id := range ids {
+			is.latest.keys[id] = items[n].Key
+		}
+		is.latest.upTo = upTo
+		is.latest.started = true
+	}
+}
+
+// the ID of each item's value.
+func extractIDs(items []storedItem, format valueFormat, extractor IDExtractor) ([]string, error) {
+	ids := make([]string, len(items))
+	for n, item := range items {
+		value, err := format.decode(item.Value)
+		if err != nil {
+			return nil, err
+		}
+		if ids[n], err = extractor.ID(value); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// the newest key for every ID, in order.
+func (is *inkSack) newestKeys(format valueFormat) ([]SplotchKey, error) {
+	if err := is.catchUpLatest(format); err != nil {
+		return nil, err
+	}
+	keys := make([]SplotchKey, 0, len(is.latest.keys))
+	for _, key := range is.latest.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].LessThan(keys[j]) })
+	return keys, nil
+}
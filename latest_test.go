@@ -0,0 +1,127 @@
+package inkdb
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkDBLatest(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := NewIDExtractor("byName", func(value any) (string, error) {
+		return value.(*testableObject).StringVal, nil
+	})
+	RegisterIDExtractor(byName)
+	if err := ink.NewTable("state", &testableObject{}, WithRollover(RolloverPolicy{MaxRows: 2}), WithLatestBy(byName)); err != nil {
+		t.Fatal(err)
+	}
+	//splotches of [a1 b1] [a2 c1] [a3 b2] [x1 x2]
+	for _, change := range []testableObject{{"a", 1}, {"b", 1}, {"a", 2}, {"c", 1}, {"a", 3}, {"b", 2}, {"x", 1}, {"x", 2}} {
+		if err := ink.Append("state", change); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkLatest := func(ink *InkDB, id string, version int, key uint64) {
+		value, found, err := ink.Latest("state", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, &testableObject{id, version}, value)
+		assert.Equal(t, KeyFromUint64(key), found)
+	}
+	checkLatest(ink, "a", 3, 5)
+	checkLatest(ink, "b", 2, 6)
+	_, _, err = ink.Latest("state", "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	//appended after the index was built.
+	if err := ink.Append("state", testableObject{"c", 2}); err != nil {
+		t.Fatal(err)
+	}
+	checkLatest(ink, "c", 2, 9)
+
+	//a1, b1, a2, c1 and x1 all have newer versions, so they go. c2 is in the last splotch, which is left alone.
+	report, err := ink.Compact("state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 5, report.RecordsDropped)
+	_, keys, _ := ink.Get("state", KeyFromUint64(0), MaxSplotchKey)
+	assert.Equal(t, []SplotchKey{KeyFromUint64(5), KeyFromUint64(6), KeyFromUint64(8), KeyFromUint64(9)}, keys)
+	checkLatest(ink, "a", 3, 5)
+
+	//tombstoning the newest doesn't bring back an older one.
+	if err := ink.Tombstone("state", KeyFromUint64(9)); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = ink.Latest("state", "c")
+	assert.ErrorIs(t, err, ErrNotFound)
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink2.Close()
+	if err := ink2.NewTable("state", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	checkLatest(ink2, "x", 2, 8)
+	//building the index reads through every splotch, but only the one holding x2 is kept loaded.
+	stats, _ := ink2.Stats("state")
+	assert.Equal(t, 1, stats.LoadedSplotches)
+	_, _, err = ink2.Latest("state", "c")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	if err := ink2.NewTable("plain", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = ink2.Latest("plain", "a")
+	assert.Error(t, err)
+}
+
+func TestInkDBLatestUnlocked(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink.Close()
+	//the first time an ID is pulled out, something else tries to use the table. It only gets in if the lock was let go.
+	appended := make(chan error, 1)
+	var once sync.Once
+	blocking := NewIDExtractor("blocking", func(value any) (string, error) {
+		once.Do(func() {
+			go func() { appended <- ink.Append("state", testableObject{"b", 1}) }()
+			select {
+			case err := <-appended:
+				appended <- err
+			case <-time.After(time.Second):
+				t.Error("the table was locked the whole time")
+			}
+		})
+		return value.(*testableObject).StringVal, nil
+	})
+	RegisterIDExtractor(blocking)
+	if err := ink.NewTable("state", &testableObject{}, WithLatestBy(blocking)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Append("state", testableObject{"a", 1}); err != nil {
+		t.Fatal(err)
+	}
+	//b1 was appended part way through, and still gets picked up.
+	value, _, err := ink.Latest("state", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &testableObject{"b", 1}, value)
+	assert.NoError(t, <-appended)
+}
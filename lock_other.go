@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || freebsd)
+
+package inkdb
+
+import "os"
+
+// there's no file locking on this system yet, so nothing stops two processes opening the same database.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build linux || darwin || freebsd
+
+package inkdb
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// takes an exclusive lock on f, failing straight away if anything else already has it.
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return ErrLocked
+	}
+	return err
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,19 @@
+package inkdb
+
+import "go.uber.org/zap"
+
+// logs what the database gets up to through logger. Without this (or with a nil logger), nothing is logged.
+// tables are logged with a "table" field, and anything to do with a single splotch gets a "splotch" field as well.
+func WithLogger(logger *zap.Logger) DBOption {
+	return func(ink *InkDB) {
+		if logger == nil {
+			logger = zap.NewNop()
+		}
+		ink.logger = logger
+	}
+}
+
+// the logger for a table's sack to use.
+func (ink *InkDB) tableLogger(name string) *zap.Logger {
+	return ink.logger.With(zap.String("table", name))
+}
@@ -0,0 +1,68 @@
+package inkdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestInkDBLogging(t *testing.T) {
+	folder := getInkTestFile()
+	core, logs := observer.New(zapcore.DebugLevel)
+	ink, err := NewInkDB(folder, WithLogger(zap.New(core)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, logs.FilterMessage("opened database").Len())
+
+	if err := ink.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	created := logs.FilterMessage("created table").All()
+	if assert.Len(t, created, 1) {
+		assert.Equal(t, "table", created[0].ContextMap()["table"])
+	}
+	if err := ink.Append("table", testableObject{StringVal: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	splotches := logs.FilterMessage("created splotch").All()
+	if assert.Len(t, splotches, 1) {
+		assert.Equal(t, "table", splotches[0].ContextMap()["table"])
+		assert.Equal(t, "s0x00000000.txt", splotches[0].ContextMap()["splotch"])
+	}
+	if err := ink.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, logs.FilterMessage("committed table").FilterField(zap.String("table", "table")).Len())
+
+	if err := ink.RenameTable("table", "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Append("renamed", testableObject{StringVal: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, logs.FilterMessage("committed table").FilterField(zap.String("table", "renamed")).Len())
+	assert.Zero(t, logs.FilterLevelExact(zapcore.ErrorLevel).Len())
+}
+
+func TestInkDBNilLogger(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder, WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink.Close()
+	if err := ink.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.Append("table", testableObject{StringVal: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, ink.Commit())
+}
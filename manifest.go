@@ -0,0 +1,105 @@
+package inkdb
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+)
+
+// the list of which splotches an inkSack has, and where each of them lives. Saved under /manifest.
+type sackManifest struct {
+	NextSplotch int             //the number used to name the next splotch file
+	Splotches   []manifestEntry //in key order
+}
+
+// where one splotch file lives.
+type manifestEntry struct {
+	DataDir  string      //which of the data directories it's in. Empty for the sack's own folder.
+	Name     string      //the file name within that directory's splotches folder
+	Checksum uint32      //crc32 of the file as of the last commit. Only kept when the sack has mirrors.
+	Summed   bool        //if Checksum has been worked out. A crc32 can come out as 0, so that can't mean there isn't one.
+	Archive  archiveStub //set while the splotch is archived
+}
+
+// if there's a checksum to check the file against. Manifests from before Summed only had a checksum if it wasn't 0.
+func (entry manifestEntry) hasChecksum() bool {
+	return entry.Summed || entry.Checksum != 0
+}
+
+// where the manifest is kept
+func (is *inkSack) manifestLocation() string {
+	return path.Join(is.localFilesLocation, "manifest")
+}
+
+// the folder splotches get stored in, for one of the data directories.
+func (is *inkSack) splotchFolder(dataDir string) string {
+	if dataDir == "" {
+		return path.Join(is.localFilesLocation, "splotches")
+	}
+	//each table gets its own folder, so several tables can share a data directory.
+	return path.Join(dataDir, path.Base(is.localFilesLocation), "splotches")
+}
+
+// the full path of the splotch file an entry points at
+func (is *inkSack) entryLocation(entry manifestEntry) string {
+	if entry.archived() {
+		return path.Join(is.splotchFolder(entry.Archive.Dir), entry.Name+archiveSuffix)
+	}
+	return path.Join(is.splotchFolder(entry.DataDir), entry.Name)
+}
+
+// reads the manifest. found is false if the sack doesn't have one yet.
+func (is *inkSack) loadManifest() (manifest sackManifest, found bool, err error) {
+	f, err := os.Open(is.manifestLocation())
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, false, nil
+	} else if err != nil {
+		return manifest, false, err
+	}
+	defer f.Close()
+	if err := gob.NewDecoder(f).Decode(&manifest); err != nil && err != io.EOF {
+		return manifest, false, err
+	}
+	return manifest, true, nil
+}
+
+// writes the manifest to disc. Like the metadata, it goes to a temp file first and is renamed over the old one,
+// so the manifest on disc always matches some real set of splotches.
+func (is *inkSack) saveManifest() error {
+	tmpLocation := is.manifestLocation() + ".tmp"
+	f, err := os.OpenFile(tmpLocation, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, is.metadata.fileMode())
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(&is.manifest); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpLocation, is.manifestLocation())
+}
+
+// builds a manifest for a sack from before manifests existed, where every splotch was in its own folder.
+func (is *inkSack) legacyManifest() (sackManifest, error) {
+	files, err := os.ReadDir(is.splotchFolder(""))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return sackManifest{}, err
+	}
+	manifest := sackManifest{NextSplotch: len(files)}
+	for _, file := range files {
+		manifest.Splotches = append(manifest.Splotches, manifestEntry{Name: file.Name()})
+	}
+	return manifest, nil
+}
+
+// the name for the next splotch file
+func (manifest *sackManifest) nextName() string {
+	name := fmt.Sprintf("s%#08x.txt", manifest.NextSplotch)
+	manifest.NextSplotch++
+	return name
+}
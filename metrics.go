@@ -0,0 +1,271 @@
+package inkdb
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// somewhere to send metrics to. Counters only ever go up, and observations get put into histograms.
+// every metric is labelled with the table it's for. Use NewMetrics for one that's ready to go, or implement this to
+// pass them along to whatever metrics library you're already using.
+type MetricsRegistry interface {
+	Add(name, table string, delta float64)
+	Observe(name, table string, value float64)
+}
+
+// sends the database's metrics to registry.
+func WithMetrics(registry MetricsRegistry) DBOption {
+	return func(ink *InkDB) {
+		ink.metrics = registry
+	}
+}
+
+// the names used for each operation that gets timed.
+type opMetric struct {
+	total    string
+	errors   string
+	duration string
+}
+
+var (
+	appendMetric      = opMetric{"inkdb_appends_total", "inkdb_append_errors_total", "inkdb_append_duration_seconds"}
+	appendBatchMetric = opMetric{"inkdb_append_batches_total", "inkdb_append_batch_errors_total", "inkdb_append_batch_duration_seconds"}
+	getMetric         = opMetric{"inkdb_gets_total", "inkdb_get_errors_total", "inkdb_get_duration_seconds"}
+	queryMetric       = opMetric{"inkdb_queries_total", "inkdb_query_errors_total", "inkdb_query_duration_seconds"}
+	commitMetric      = opMetric{"inkdb_commits_total", "inkdb_commit_errors_total", "inkdb_commit_duration_seconds"}
+	splotchLoadMetric = opMetric{"inkdb_splotch_loads_total", "inkdb_splotch_load_errors_total", "inkdb_splotch_load_duration_seconds"}
+	splotchSaveMetric = opMetric{"inkdb_splotch_saves_total", "inkdb_splotch_save_errors_total", "inkdb_splotch_save_duration_seconds"}
+)
+
+const (
+	appendBatchRecordsMetric = "inkdb_append_batch_records_total"
+	getRecordsMetric         = "inkdb_get_records_total"
+	queryRecordsMetric       = "inkdb_query_records_total"
+	readBytesMetric          = "inkdb_read_bytes_total"
+	writtenBytesMetric       = "inkdb_written_bytes_total"
+	retentionRecordsMetric   = "inkdb_retention_removed_records_total"
+)
+
+// the help text for every metric InkDB sends.
+var metricHelp = map[string]string{
+	appendMetric.total:         "Appends to the table.",
+	appendMetric.errors:        "Appends to the table that failed.",
+	appendMetric.duration:      "How long appends to the table took.",
+	appendBatchMetric.total:    "Batches appended to the table.",
+	appendBatchMetric.errors:   "Batches appended to the table that failed.",
+	appendBatchMetric.duration: "How long appending batches to the table took.",
+	appendBatchRecordsMetric:   "Records appended to the table in batches.",
+	getMetric.total:            "Gets from the table.",
+	getMetric.errors:           "Gets from the table that failed.",
+	getMetric.duration:         "How long gets from the table took.",
+	getRecordsMetric:           "Records returned by gets from the table.",
+	queryMetric.total:          "Queries of the table.",
+	queryMetric.errors:         "Queries of the table that failed.",
+	queryMetric.duration:       "How long queries of the table took.",
+	queryRecordsMetric:         "Records kept by queries of the table.",
+	commitMetric.total:         "Commits of the table.",
+	commitMetric.errors:        "Commits of the table that failed.",
+	commitMetric.duration:      "How long commits of the table took.",
+	splotchLoadMetric.total:    "Splotches fully loaded from disc.",
+	splotchLoadMetric.errors:   "Splotches that failed to load from disc.",
+	splotchLoadMetric.duration: "How long fully loading a splotch took.",
+	splotchSaveMetric.total:    "Splotches saved to disc.",
+	splotchSaveMetric.errors:   "Splotches that failed to save to disc.",
+	splotchSaveMetric.duration: "How long saving a splotch took.",
+	readBytesMetric:            "Bytes read from splotch files.",
+	writtenBytesMetric:         "Bytes written to splotch files.",
+	retentionRecordsMetric:     "Records removed from the table by its retention policy.",
+}
+
+// what a table (and its splotches) sends its metrics through. Safe to use when nil, or without a registry,
+// in which case nothing is sent anywhere.
+type tableMetrics struct {
+	registry MetricsRegistry
+	table    atomic.Value //the table's name, as a string. RenameTable can change it while something's still recording
+}
+
+func newTableMetrics(registry MetricsRegistry, table string) *tableMetrics {
+	tm := &tableMetrics{registry: registry}
+	tm.rename(table)
+	return tm
+}
+
+// the name the metrics are sent under.
+func (tm *tableMetrics) name() string {
+	return tm.table.Load().(string)
+}
+
+// sends everything from now on under table instead.
+func (tm *tableMetrics) rename(table string) {
+	tm.table.Store(table)
+}
+
+func (tm *tableMetrics) add(name string, delta float64) {
+	if tm == nil || tm.registry == nil {
+		return
+	}
+	tm.registry.Add(name, tm.name(), delta)
+}
+
+// counts an operation that was started at start, and how long it took.
+func (tm *tableMetrics) record(op opMetric, start time.Time, err error) {
+	if tm == nil || tm.registry == nil {
+		return
+	}
+	table := tm.name()
+	tm.registry.Add(op.total, table, 1)
+	if err != nil {
+		tm.registry.Add(op.errors, table, 1)
+	}
+	tm.registry.Observe(op.duration, table, time.Since(start).Seconds())
+}
+
+// the upper bounds of the histogram buckets, in seconds.
+var defaultBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+type metricKey struct {
+	name  string
+	table string
+}
+
+type histogram struct {
+	counts []uint64 //one for each bucket, not including the ones below it
+	count  uint64
+	sum    float64
+}
+
+// a MetricsRegistry that keeps everything in memory. It's also an http.Handler, serving them all up in the
+// prometheus text format.
+type Metrics struct {
+	mu         sync.Mutex
+	counters   map[metricKey]float64
+	histograms map[metricKey]*histogram
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		counters:   map[metricKey]float64{},
+		histograms: map[metricKey]*histogram{},
+	}
+}
+
+func (m *Metrics) Add(name, table string, delta float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[metricKey{name, table}] += delta
+}
+
+func (m *Metrics) Observe(name, table string, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := metricKey{name, table}
+	h := m.histograms[key]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(defaultBuckets))}
+		m.histograms[key] = h
+	}
+	for i, bound := range defaultBuckets {
+		if value <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// the current value of a counter.
+func (m *Metrics) Counter(name, table string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[metricKey{name, table}]
+}
+
+// how many observations a histogram has had.
+func (m *Metrics) Observations(name, table string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if h := m.histograms[metricKey{name, table}]; h != nil {
+		return h.count
+	}
+	return 0
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	out := bufio.NewWriter(w)
+	m.writeText(out)
+	out.Flush()
+}
+
+// writes every metric out in the prometheus text format, grouped by name.
+func (m *Metrics) writeText(out *bufio.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counterKeys := make([]metricKey, 0, len(m.counters))
+	for key := range m.counters {
+		counterKeys = append(counterKeys, key)
+	}
+	histogramKeys := make([]metricKey, 0, len(m.histograms))
+	for key := range m.histograms {
+		histogramKeys = append(histogramKeys, key)
+	}
+	sortMetricKeys(counterKeys)
+	sortMetricKeys(histogramKeys)
+
+	for i, key := range counterKeys {
+		if i == 0 || counterKeys[i-1].name != key.name {
+			writeMetricHeader(out, key.name, "counter")
+		}
+		fmt.Fprintf(out, "%v{table=%v} %v\n", key.name, quoteLabel(key.table), formatFloat(m.counters[key]))
+	}
+	for i, key := range histogramKeys {
+		if i == 0 || histogramKeys[i-1].name != key.name {
+			writeMetricHeader(out, key.name, "histogram")
+		}
+		h := m.histograms[key]
+		table := quoteLabel(key.table)
+		var cumulative uint64
+		for b, bound := range defaultBuckets {
+			cumulative += h.counts[b]
+			fmt.Fprintf(out, "%v_bucket{table=%v,le=\"%v\"} %v\n", key.name, table, formatFloat(bound), cumulative)
+		}
+		fmt.Fprintf(out, "%v_bucket{table=%v,le=\"+Inf\"} %v\n", key.name, table, h.count)
+		fmt.Fprintf(out, "%v_sum{table=%v} %v\n", key.name, table, formatFloat(h.sum))
+		fmt.Fprintf(out, "%v_count{table=%v} %v\n", key.name, table, h.count)
+	}
+}
+
+func writeMetricHeader(out *bufio.Writer, name, kind string) {
+	if help, ok := metricHelp[name]; ok {
+		fmt.Fprintf(out, "# HELP %v %v\n", name, help)
+	}
+	fmt.Fprintf(out, "# TYPE %v %v\n", name, kind)
+}
+
+func sortMetricKeys(keys []metricKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].table < keys[j].table
+	})
+}
+
+// quotes a label value, escaping it the way the text format wants.
+func quoteLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
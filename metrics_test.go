@@ -0,0 +1,110 @@
+package inkdb
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkDBMetrics(t *testing.T) {
+	folder := getInkTestFile()
+	metrics := NewMetrics()
+	ink, err := NewInkDB(folder, WithMetrics(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("table", []byte{}, WithCodec(rawCodec{})); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := ink.Append("table", []byte("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ink.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 5.0, metrics.Counter(appendMetric.total, "table"))
+	assert.Equal(t, uint64(5), metrics.Observations(appendMetric.duration, "table"))
+	assert.Equal(t, 1.0, metrics.Counter(commitMetric.total, "table"))
+	assert.Less(t, 50.0, metrics.Counter(writtenBytesMetric, "table"))
+
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//a fresh database has to load the splotch to read from it.
+	ink2, err := NewInkDB(folder, WithMetrics(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink2.NewTable("table", []byte{}); err != nil {
+		t.Fatal(err)
+	}
+	found, _, err := ink2.Get("table", KeyFromUint64(1), KeyFromUint64(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, found, 5)
+	assert.Equal(t, 1.0, metrics.Counter(getMetric.total, "table"))
+	assert.Equal(t, 5.0, metrics.Counter(getRecordsMetric, "table"))
+	assert.Equal(t, 1.0, metrics.Counter(splotchLoadMetric.total, "table"))
+	assert.Less(t, 50.0, metrics.Counter(readBytesMetric, "table"))
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	assert.Contains(t, body, "# TYPE inkdb_appends_total counter\n")
+	assert.Contains(t, body, "inkdb_appends_total{table=\"table\"} 5\n")
+	assert.Contains(t, body, "# TYPE inkdb_append_duration_seconds histogram\n")
+	assert.Contains(t, body, "inkdb_append_duration_seconds_bucket{table=\"table\",le=\"+Inf\"} 5\n")
+	assert.Contains(t, body, "inkdb_append_duration_seconds_count{table=\"table\"} 5\n")
+}
+
+func TestInkDBMetricsRename(t *testing.T) {
+	folder := getInkTestFile()
+	metrics := NewMetrics()
+	ink, err := NewInkDB(folder, WithMetrics(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink.Close()
+	if err := ink.NewTable("table", []byte{}, WithCodec(rawCodec{})); err != nil {
+		t.Fatal(err)
+	}
+	//appends record their metrics after letting go of the table, so they can still be going while it's renamed.
+	//the race detector (go test -race) is what catches them stepping on each other.
+	started, appended := make(chan struct{}), make(chan int)
+	for g := 0; g < 8; g++ {
+		go func() {
+			count := 0
+			for i := 0; i < 200; i++ {
+				if i == 1 && g == 0 {
+					close(started)
+				}
+				for _, name := range []string{"table", "renamed"} {
+					if ink.Append(name, []byte("0123456789")) == nil {
+						count++
+					}
+				}
+			}
+			appended <- count
+		}()
+	}
+	<-started
+	if err := ink.RenameTable("table", "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for g := 0; g < 8; g++ {
+		count += <-appended
+	}
+	assert.Equal(t, float64(count), metrics.Counter(appendMetric.total, "table")+metrics.Counter(appendMetric.total, "renamed"))
+
+	before := metrics.Counter(appendMetric.total, "renamed")
+	if err := ink.Append("renamed", []byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, before+1, metrics.Counter(appendMetric.total, "renamed"))
+}
@@ -0,0 +1,211 @@
+package inkdb
+
+import (
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+
+	"go.uber.org/zap"
+)
+
+// keep a full copy of every splotch in each of dirs, written on Commit. Reads use a mirror whenever the main copy is missing or damaged.
+func WithMirrors(dirs ...string) TableOption {
+	return func(md *sackMetadata) {
+		md.Mirrors = dirs
+	}
+}
+
+// what a scrub found, and what it fixed.
+type ScrubReport struct {
+	Checked       int      //how many splotches were looked at
+	Repaired      []string //every copy that was rewritten from a good one
+	Unrecoverable []string //splotches with no good copy left anywhere
+}
+
+// every copy of the splotch file for entry. The main copy always comes first. Archived splotches have their archive
+// mirrored instead.
+func (is *inkSack) entryCopies(entry manifestEntry) []string {
+	copies := []string{is.entryLocation(entry)}
+	for _, mirror := range is.metadata.Mirrors {
+		copies = append(copies, is.mirrorLocation(mirror, entry))
+	}
+	return copies
+}
+
+// where a mirror keeps its copy of a splotch.
+func (is *inkSack) mirrorLocation(mirror string, entry manifestEntry) string {
+	if entry.archived() {
+		return path.Join(is.splotchFolder(mirror), entry.Name+archiveSuffix)
+	}
+	return is.entryLocation(manifestEntry{DataDir: mirror, Name: entry.Name})
+}
+
+// finds a copy of the splotch that matches its checksum. If none do (or there's no checksum yet), the main copy is used.
+func (is *inkSack) healthyCopy(entry manifestEntry) (location string, healthy bool) {
+	if !entry.hasChecksum() {
+		return is.entryLocation(entry), true
+	}
+	for _, location := range is.entryCopies(entry) {
+		if sum, err := fileChecksum(location); err == nil && sum == entry.Checksum {
+			return location, true
+		}
+	}
+	return is.entryLocation(entry), false
+}
+
+// copies every changed splotch out to the mirrors, and records their new checksums.
+func (is *inkSack) writeMirrors(changed []int) error {
+	if len(is.metadata.Mirrors) == 0 {
+		return nil
+	}
+	for _, i := range changed {
+		entry := is.manifest.Splotches[i]
+		sum, err := fileChecksum(is.entryLocation(entry))
+		if err != nil {
+			return err
+		}
+		for _, mirror := range is.metadata.Mirrors {
+			if err := copyFile(is.entryLocation(entry), is.mirrorLocation(mirror, entry), is.metadata.fileMode()); err != nil {
+				return err
+			}
+		}
+		is.manifest.Splotches[i].Checksum = sum
+		is.manifest.Splotches[i].Summed = true
+		is.inkSplotches[i].setChecksum(sum)
+		is.manifestChanged = true
+	}
+	return nil
+}
+
+// copies every committed splotch into mirrors that have just been added, so they start out as full copies like the
+// others. Splotches that were never mirrored get their checksums worked out on the way.
+func (is *inkSack) fillMirrors(mirrors []string) error {
+	if len(mirrors) == 0 {
+		return nil
+	}
+	for i, entry := range is.manifest.Splotches {
+		from, healthy := is.healthyCopy(entry)
+		if !healthy {
+			//nothing good to copy, a scrub will report it.
+			is.logger.Error("every copy of splotch is bad, so it can't be mirrored", zap.String("splotch", entry.Name))
+			continue
+		}
+		if !entry.hasChecksum() {
+			sum, err := fileChecksum(from)
+			if err != nil {
+				return err
+			}
+			is.manifest.Splotches[i].Checksum = sum
+			is.manifest.Splotches[i].Summed = true
+			is.inkSplotches[i].setChecksum(sum)
+			is.manifestChanged = true
+		}
+		for _, mirror := range mirrors {
+			if err := copyFile(from, is.mirrorLocation(mirror, entry), is.metadata.fileMode()); err != nil {
+				return err
+			}
+		}
+	}
+	if !is.manifestChanged {
+		return nil
+	}
+	if err := is.saveManifest(); err != nil {
+		return err
+	}
+	is.manifestChanged = false
+	return nil
+}
+
+// the dirs in now that weren't in before.
+func addedDirs(before, now []string) []string {
+	known := map[string]bool{}
+	for _, dir := range before {
+		known[dir] = true
+	}
+	added := []string{}
+	for _, dir := range now {
+		if !known[dir] {
+			added = append(added, dir)
+		}
+	}
+	return added
+}
+
+// if the main copy of a splotch has gone missing or bad, point its reads at a mirror that's still good.
+// returns false if there was nowhere better to read from.
+func (is *inkSack) fallBackToMirror(i int) bool {
+	if len(is.metadata.Mirrors) == 0 {
+		return false
+	}
+	location, healthy := is.healthyCopy(is.manifest.Splotches[i])
+	splotch := is.inkSplotches[i]
+	if !healthy || location == splotch.loadLocation() {
+		return false
+	}
+	splotch.readLocation = location
+	splotch.hasFullyLoaded = false
+	return true
+}
+
+// puts a good copy back as the main one, before anything new gets written on top of it.
+func (is *inkSack) restoreFromMirror(i int) error {
+	splotch := is.inkSplotches[i]
+	if splotch.readLocation == "" {
+		return nil
+	}
+	if err := copyFile(splotch.readLocation, splotch.fileLocation, is.metadata.fileMode()); err != nil {
+		return err
+	}
+	is.logger.Info("restored splotch from a mirror", zap.String("splotch", is.manifest.Splotches[i].Name), zap.String("mirror", splotch.readLocation))
+	splotch.readLocation = ""
+	return nil
+}
+
+// checks every copy of every committed splotch, and rewrites any bad or missing ones from a good copy.
+func (is *inkSack) Scrub() (ScrubReport, error) {
+	report := ScrubReport{}
+	for i, entry := range is.manifest.Splotches {
+		report.Checked++
+		if !entry.hasChecksum() {
+			//never been mirrored, so there's nothing to check it against.
+			continue
+		}
+		good, healthy := is.healthyCopy(entry)
+		if !healthy {
+			report.Unrecoverable = append(report.Unrecoverable, is.entryLocation(entry))
+			is.logger.Error("every copy of splotch is bad", zap.String("splotch", entry.Name))
+			continue
+		}
+		for _, location := range is.entryCopies(entry) {
+			if sum, err := fileChecksum(location); err == nil && sum == entry.Checksum {
+				continue
+			} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return report, err
+			}
+			if err := copyFile(good, location, is.metadata.fileMode()); err != nil {
+				return report, err
+			}
+			report.Repaired = append(report.Repaired, location)
+			is.logger.Warn("repaired bad copy of splotch", zap.String("splotch", entry.Name), zap.String("location", location))
+		}
+		//the main copy is good again, so reads can go back to it.
+		is.inkSplotches[i].readLocation = ""
+	}
+	return report, nil
+}
+
+// the crc32 of a whole file.
+func fileChecksum(location string) (uint32, error) {
+	f, err := os.Open(location)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	hash := crc32.NewIEEE()
+	if _, err := io.Copy(hash, f); err != nil {
+		return 0, err
+	}
+	return hash.Sum32(), nil
+}
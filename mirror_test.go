@@ -0,0 +1,207 @@
+package inkdb
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkSackMirrors(t *testing.T) {
+	folder := getSackTestFolder()
+	mirrors := []string{path.Join(folder, "mirrorA"), path.Join(folder, "mirrorB")}
+	for _, dir := range mirrors {
+		os.RemoveAll(dir)
+	}
+	is, err := NewInkSack(folder, WithMirrors(mirrors...), WithRollover(RolloverPolicy{MaxRows: 10}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 30; i++ {
+		if err := is.AutoAppend([]byte(fmt.Sprintf("%010v", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := is.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	//lose one splotch entirely, and damage another.
+	if err := os.Remove(is.entryLocation(is.manifest.Splotches[0])); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(is.entryLocation(is.manifest.Splotches[1]), []byte("not a splotch"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	//and one of the mirrors of the last one.
+	if err := os.Remove(is.mirrorLocation(mirrors[1], is.manifest.Splotches[2])); err != nil {
+		t.Fatal(err)
+	}
+
+	is2, err := NewInkSack(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := is2.GetAll(SplotchKey{}, SplotchKey{}.Plus(30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Equal(t, 30, len(items)) {
+		for i, item := range items {
+			assert.Equal(t, []byte(fmt.Sprintf("%010v", i)), item.Value)
+		}
+	}
+
+	report, err := is2.Scrub()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, report.Checked)
+	assert.Equal(t, 3, len(report.Repaired))
+	assert.Empty(t, report.Unrecoverable)
+	//everything should be good now, so another scrub has nothing to do.
+	report, err = is2.Scrub()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, report.Repaired)
+}
+
+func TestInkSackMirrorsChecksum(t *testing.T) {
+	folder := getSackTestFolder()
+	mirror := path.Join(folder, "mirror")
+	is, err := NewInkSack(folder, WithMirrors(mirror), WithRollover(RolloverPolicy{MaxRows: 10}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := is.AutoAppend([]byte(fmt.Sprintf("%010v", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := is.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	is2, err := NewInkSack(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//a value that still decodes, but isn't what was stored. Only the checksum can catch it.
+	main := is2.entryLocation(is2.manifest.Splotches[0])
+	contents, err := os.ReadFile(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	damaged := bytes.Replace(contents, []byte("0000000003"), []byte("000000000X"), 1)
+	if !assert.NotEqual(t, contents, damaged) {
+		return
+	}
+	if err := os.WriteFile(main, damaged, 0644); err != nil {
+		t.Fatal(err)
+	}
+	items, err := is2.GetAll(SplotchKey{}, SplotchKey{}.Plus(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Equal(t, 20, len(items)) {
+		assert.Equal(t, []byte("0000000003"), items[3].Value)
+	}
+
+	//with the mirror damaged too, there's nothing good left to read.
+	if err := os.WriteFile(is2.mirrorLocation(mirror, is2.manifest.Splotches[0]), damaged, 0644); err != nil {
+		t.Fatal(err)
+	}
+	is3, err := NewInkSack(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = is3.GetAll(SplotchKey{}, SplotchKey{}.Plus(20))
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	//a checksum of 0 is still a checksum.
+	entry := is3.manifest.Splotches[1]
+	entry.Checksum, entry.Summed = 0, true
+	_, healthy := is3.healthyCopy(entry)
+	assert.False(t, healthy)
+
+	//a splotch the manifest lists isn't made again from nothing just because it's gone.
+	for _, location := range is3.entryCopies(is3.manifest.Splotches[0]) {
+		if err := os.Remove(location); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = NewInkSack(folder)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestInkDBAddMirrors(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("table", []byte{}, WithCodec(rawCodec{}), WithRollover(RolloverPolicy{MaxRows: 2})); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := ink.Append("table", []byte(fmt.Sprintf("%010v", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//a mirror added on a reopen should get a copy of everything already committed, not just what comes after.
+	mirror := path.Join(folder, "newMirror")
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink2.NewTable("table", []byte{}, WithMirrors(mirror)); err != nil {
+		t.Fatal(err)
+	}
+	sack := ink2.inkSacks["table"]
+	assert.Equal(t, 3, len(sack.manifest.Splotches))
+	for _, entry := range sack.manifest.Splotches {
+		assert.True(t, entry.hasChecksum())
+		sum, err := fileChecksum(sack.mirrorLocation(mirror, entry))
+		if assert.NoError(t, err) {
+			assert.Equal(t, entry.Checksum, sum)
+		}
+	}
+	for i := 5; i < 8; i++ {
+		if err := ink2.Append("table", []byte(fmt.Sprintf("%010v", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ink2.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//so losing the main copy of an old splotch is fine.
+	if err := os.Remove(sack.entryLocation(sack.manifest.Splotches[0])); err != nil {
+		t.Fatal(err)
+	}
+	ink3, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink3.Close()
+	if err := ink3.NewTable("table", []byte{}); err != nil {
+		t.Fatal(err)
+	}
+	vals, _, err := ink3.Get("table", SplotchKey{}, SplotchKey{255, 255, 255, 255, 255, 255, 255, 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Equal(t, 8, len(vals)) {
+		for i := range vals {
+			assert.Equal(t, []byte(fmt.Sprintf("%010v", i)), vals[i])
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package inkdb
+
+import (
+	"os"
+	"time"
+)
+
+// changes how a table is setup. All of these get saved with the table, and are applied again when the database reopens.
+type TableOption func(*sackMetadata)
+
+// how hard a table tries to make sure a Commit has actually reached the disc.
+type Durability int
+
+const (
+	//leave the writes to the OS. Fast, but a power cut can lose the last commit.
+	DurabilityBuffered Durability = iota
+	//sync every changed splotch file before Commit returns.
+	DurabilitySync
+)
+
+// which codec to encode the table's values with. Defaults to gob. Anything but the built in ones needs RegisterCodec first.
+func WithCodec(codec Codec) TableOption {
+	return func(md *sackMetadata) {
+		md.Codec = codec.Name()
+	}
+}
+
+// compress each value after it's been encoded.
+func WithCompression(compression Compression) TableOption {
+	return func(md *sackMetadata) {
+		md.Compression = compression
+	}
+}
+
+// how keys are picked for appended items. Defaults to counting up by one. Anything but the built in ones needs
+// RegisterKeyGenerator first.
+func WithKeyGenerator(generator KeyGenerator) TableOption {
+	return func(md *sackMetadata) {
+		md.KeyGenerator = generator.Name()
+	}
+}
+
+// when splotches are closed off, and the next one started.
+func WithRollover(policy RolloverPolicy) TableOption {
+	return func(md *sackMetadata) {
+		md.Rollover = policy
+	}
+}
+
+// how much of the table's history should be kept.
+func WithRetention(policy RetentionPolicy) TableOption {
+	return func(md *sackMetadata) {
+		md.Retention = policy
+	}
+}
+
+// how careful Commit is about getting the data onto the disc.
+func WithDurability(durability Durability) TableOption {
+	return func(md *sackMetadata) {
+		md.Durability = durability
+	}
+}
+
+// the permissions the table's folders and files get created with. Defaults to 0777 and 0644.
+func WithFileModes(dirMode, fileMode os.FileMode) TableOption {
+	return func(md *sackMetadata) {
+		md.DirMode = dirMode
+		md.FileMode = fileMode
+	}
+}
+
+// what decides how a table's values and keys are stored, with the defaults filled in. None of it can change once the
+// table holds anything, or what's already there couldn't be read back.
+type storageFormat struct {
+	codec        string
+	compression  Compression
+	keyGenerator string
+	latestBy     string
+}
+
+func (md sackMetadata) storageFormat() storageFormat {
+	format := storageFormat{codec: md.Codec, compression: md.Compression, keyGenerator: md.KeyGenerator, latestBy: md.LatestBy}
+	if format.codec == "" {
+		format.codec = GobCodec{}.Name()
+	}
+	if format.keyGenerator == "" {
+		format.keyGenerator = SequentialKeys{}.Name()
+	}
+	return format
+}
+
+// makes sure everything the options name has been registered, so the table can actually be used.
+func (md sackMetadata) checkRegistered() error {
+	if _, err := lookupCodec(md.Codec); err != nil {
+		return err
+	}
+	if _, err := lookupKeyGenerator(md.KeyGenerator); err != nil {
+		return err
+	}
+	if md.LatestBy != "" {
+		if _, err := lookupIDExtractor(md.LatestBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the folder permissions to use, or the default if none were given.
+func (md sackMetadata) dirMode() os.FileMode {
+	if md.DirMode == 0 {
+		return 0777
+	}
+	return md.DirMode
+}
+
+// the file permissions to use, or the default if none were given.
+func (md sackMetadata) fileMode() os.FileMode {
+	if md.FileMode == 0 {
+		return 0644
+	}
+	return md.FileMode
+}
+
+// how much of a table to keep. Each limit is ignored while it's left at zero. Enforced by EnforceRetention (or WithMaintenance),
+// which only ever removes whole splotches, oldest first.
+type RetentionPolicy struct {
+	KeepRecords int           //keep (at least) the newest this many records
+	KeepFor     time.Duration //keep records added within this long
+	KeepBytes   int64         //keep at most this many bytes of values
+}
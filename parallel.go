@@ -0,0 +1,150 @@
+package inkdb
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// how many splotches GetParallel works on at once. Left at 0, it's one per CPU.
+func WithScanParallelism(workers int) DBOption {
+	return func(ink *InkDB) {
+		ink.scanParallelism = workers
+	}
+}
+
+// how many workers a parallel read gets.
+func (ink *InkDB) parallelism() int {
+	if ink.scanParallelism > 0 {
+		return ink.scanParallelism
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// the same as Get, but the splotches in the range are loaded and decoded side by side, up to WithScanParallelism at a
+// time. The values still come back in key order. Worth it for big ranges, where decoding is what takes the time.
+func (ink *InkDB) GetParallel(inksack string, from, to SplotchKey) ([]any, []SplotchKey, error) {
+	return ink.GetParallelCtx(context.Background(), inksack, from, to)
+}
+
+// the same as GetParallel, but gives up once ctx is done, returning ctx.Err().
+func (ink *InkDB) GetParallelCtx(ctx context.Context, inksack string, from, to SplotchKey) (_ []any, _ []SplotchKey, err error) {
+	sack, format, err := ink.getSackAndFormat(inksack)
+	if err != nil {
+		return nil, nil, err
+	}
+	start := time.Now()
+	defer func() {
+		sack.metrics.record(getMetric, start, err)
+	}()
+	workers := ink.parallelism()
+	parts, err := sack.getAllParallel(ctx, from, to, workers)
+	if err != nil {
+		return nil, nil, err
+	}
+	//each splotch's values go straight into their place in the answer, so nothing needs sorting afterwards.
+	offsets := make([]int, len(parts))
+	total := 0
+	for p, items := range parts {
+		offsets[p] = total
+		total += len(items)
+	}
+	values := make([]any, total)
+	keys := make([]SplotchKey, total)
+	//the sack is unlocked by now, so appends carry on while this runs.
+	err = parallelEach(ctx, workers, len(parts), func(p int) error {
+		for j, item := range parts[p] {
+			value, err := format.decode(item.Value)
+			if err != nil {
+				return err
+			}
+			values[offsets[p]+j] = value
+			keys[offsets[p]+j] = item.Key
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sack.metrics.add(getRecordsMetric, float64(total))
+	return values, keys, nil
+}
+
+// the items from <from> to <to>, one slice for each splotch in the range, in key order. The splotches are loaded on up
+// to workers goroutines at once. Each only ever touches its own splotch, so the sack's lock covers them all.
+func (is *inkSack) getAllParallel(ctx context.Context, from, to SplotchKey, workers int) ([][]storedItem, error) {
+	if err := is.lock(); err != nil {
+		return nil, err
+	}
+	defer is.mu.Unlock()
+	inRange := []int{}
+	for i, splotch := range is.inkSplotches {
+		if splotch.headings.LinesStored == 0 || from.GreaterThan(splotch.headings.LargestKey) || to.LessThan(splotch.smallestKey) {
+			continue
+		}
+		inRange = append(inRange, i)
+	}
+	parts := make([][]storedItem, len(inRange))
+	err := parallelEach(ctx, workers, len(inRange), func(p int) error {
+		items, err := is.splotchGetAll(inRange[p], from, to)
+		if err == ErrSplotchRangeExceeded {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		parts[p] = is.hideTombstoned(items)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// calls fn for every index below count, on up to workers goroutines at once. Once fn fails (or ctx is done), no more
+// indexes are handed out, and the first error is returned after the ones already running have finished.
+func parallelEach(ctx context.Context, workers, count int, fn func(i int) error) error {
+	if workers > count {
+		workers = count
+	}
+	var (
+		wg       sync.WaitGroup
+		next     int64 = -1
+		failed   atomic.Bool
+		mu       sync.Mutex
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		failed.Store(true)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !failed.Load() {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= count {
+					return
+				}
+				if err := ctx.Err(); err != nil {
+					fail(err)
+					return
+				}
+				if err := fn(i); err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
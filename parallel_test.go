@@ -0,0 +1,92 @@
+package inkdb
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkDBGetParallel(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder, WithScanParallelism(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink.Close()
+	if err := ink.NewTable("table", &testableObject{}, WithRollover(RolloverPolicy{MaxRows: 4})); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		if err := ink.Append("table", generateTestableObject(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//half of them committed, so some splotches need loading and some are still in memory.
+	if err := ink.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 50; i < 100; i++ {
+		if err := ink.Append("table", generateTestableObject(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ink.Tombstone("table", KeyFromUint64(20)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range [][2]uint64{{0, 1000}, {7, 93}, {10, 10}, {20, 20}, {200, 300}} {
+		from, to := KeyFromUint64(r[0]), KeyFromUint64(r[1])
+		values, keys, err := ink.Get("table", from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parallelValues, parallelKeys, err := ink.GetParallel("table", from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, keys, parallelKeys)
+		assert.Equal(t, values, parallelValues)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = ink.GetParallelCtx(ctx, "table", KeyFromUint64(0), MaxSplotchKey)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, _, err = ink.GetParallel("missing", KeyFromUint64(0), MaxSplotchKey)
+	assert.ErrorIs(t, err, ErrNoTable)
+}
+
+func TestParallelEach(t *testing.T) {
+	var running, most, calls int64
+	err := parallelEach(context.Background(), 4, 100, func(i int) error {
+		now := atomic.AddInt64(&running, 1)
+		for {
+			seen := atomic.LoadInt64(&most)
+			if now <= seen || atomic.CompareAndSwapInt64(&most, seen, now) {
+				break
+			}
+		}
+		atomic.AddInt64(&calls, 1)
+		atomic.AddInt64(&running, -1)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), calls)
+	assert.LessOrEqual(t, most, int64(4))
+
+	//the first error stops it.
+	failed := errors.New("failed")
+	calls = 0
+	err = parallelEach(context.Background(), 1, 100, func(i int) error {
+		atomic.AddInt64(&calls, 1)
+		if i == 10 {
+			return failed
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, failed)
+	assert.Equal(t, int64(11), calls)
+}
@@ -0,0 +1,154 @@
+package inkdb
+
+import (
+	"io"
+	"os"
+	"path"
+
+	"go.uber.org/zap"
+)
+
+// how an inkSack with several data directories decides where each new splotch goes.
+type PlacementPolicy int
+
+const (
+	//take turns between each of the directories
+	RoundRobin PlacementPolicy = iota
+	//put it wherever has the most free space. Falls back to RoundRobin where free space can't be found out.
+	MostFreeSpace
+	//new splotches go on the first (hot) directory. Once too many are there, the oldest get moved on to the last (cold) one.
+	HotColdTiers
+)
+
+// how many splotches stay on the hot directory when using HotColdTiers, if it isn't set.
+const defaultHotSplotches = 4
+
+// spread the table's splotches over several folders, usually on different drives.
+func WithDataDirs(placement PlacementPolicy, dirs ...string) TableOption {
+	return func(md *sackMetadata) {
+		md.DataDirs = dirs
+		md.Placement = placement
+	}
+}
+
+// how many splotches are kept on the hot directory with HotColdTiers, before the oldest get moved to the cold one.
+func WithHotSplotches(count int) TableOption {
+	return func(md *sackMetadata) {
+		md.HotSplotches = count
+	}
+}
+
+// every directory this sack can place splotches in. Empty means the sack's own folder.
+func (is *inkSack) dataDirs() []string {
+	if len(is.metadata.DataDirs) == 0 {
+		return []string{""}
+	}
+	return is.metadata.DataDirs
+}
+
+// picks which data directory the next splotch goes in.
+func (is *inkSack) pickDataDir() string {
+	dirs := is.dataDirs()
+	switch is.metadata.Placement {
+	case MostFreeSpace:
+		best := -1
+		var bestFree uint64
+		for i, dir := range dirs {
+			free, known := freeSpace(is.splotchFolder(dir))
+			if !known {
+				best = -1
+				break
+			}
+			if best == -1 || free > bestFree {
+				best, bestFree = i, free
+			}
+		}
+		if best != -1 {
+			return dirs[best]
+		}
+	case HotColdTiers:
+		return dirs[0]
+	}
+	return dirs[is.manifest.NextSplotch%len(dirs)]
+}
+
+// for HotColdTiers, moves the oldest splotches off of the hot directory once there are too many there.
+// only ever moves splotches that are full, and fully committed.
+func (is *inkSack) moveColdSplotches() error {
+	dirs := is.dataDirs()
+	if is.metadata.Placement != HotColdTiers || len(dirs) < 2 {
+		return nil
+	}
+	hot, cold := dirs[0], dirs[len(dirs)-1]
+	keepHot := is.metadata.HotSplotches
+	if keepHot <= 0 {
+		keepHot = defaultHotSplotches
+	}
+	onHot := 0
+	for _, entry := range is.manifest.Splotches {
+		if entry.DataDir == hot && !entry.archived() {
+			onHot++
+		}
+	}
+	//the last splotch is still being filled, so it never moves.
+	for i := 0; i < len(is.inkSplotches)-1 && onHot > keepHot; i++ {
+		entry := is.manifest.Splotches[i]
+		splotch := is.inkSplotches[i]
+		if entry.DataDir != hot || entry.archived() || !splotch.IsFull() || len(splotch.unsavedItems) != 0 {
+			continue
+		}
+		movedEntry := entry
+		movedEntry.DataDir = cold
+		if err := copyFile(is.entryLocation(entry), is.entryLocation(movedEntry), is.metadata.fileMode()); err != nil {
+			return err
+		}
+		//the manifest has to point at the new copy before the old one can go.
+		is.manifest.Splotches[i] = movedEntry
+		if err := is.saveManifest(); err != nil {
+			is.manifest.Splotches[i] = entry
+			return err
+		}
+		splotch.fileLocation = is.entryLocation(movedEntry)
+		is.logger.Debug("moved splotch to the cold tier", zap.String("splotch", entry.Name))
+		if err := os.Remove(is.entryLocation(entry)); err != nil {
+			return err
+		}
+		onHot--
+	}
+	return nil
+}
+
+// copies the file at from to to, making sure it's on the disc before returning.
+func copyFile(from, to string, mode os.FileMode) error {
+	return transformFile(from, to, mode, func(out io.Writer, in io.Reader) error {
+		_, err := io.Copy(out, in)
+		return err
+	})
+}
+
+// copies the file at from to to, passing its contents through transform on the way. The copy is written to a temp
+// file and synced before it's renamed into place, so to is never left half written.
+func transformFile(from, to string, mode os.FileMode, transform func(out io.Writer, in io.Reader) error) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmpLocation := path.Join(path.Dir(to), "."+path.Base(to)+".tmp")
+	out, err := os.OpenFile(tmpLocation, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if err := transform(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpLocation, to)
+}
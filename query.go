@@ -0,0 +1,83 @@
+package inkdb
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// what Query keeps, and how many. Either predicate can be left nil to keep everything.
+type QueryFilter struct {
+	Raw    func(key SplotchKey, raw []byte) bool //run on the value as the codec wrote it (decompressed, if the table compresses), before it's decoded. Anything it turns down is never decoded
+	Value  func(key SplotchKey, value any) bool  //run on the decoded value
+	Offset int                                   //how many matching records to pass over before keeping any
+	Limit  int                                   //the most records to keep. No limit if left at 0
+}
+
+// the records a Query kept, along with how much work it took to find them.
+type QueryResult struct {
+	Values  []any
+	Keys    []SplotchKey
+	Decoded int //records that were decoded
+	Skipped int //records turned down by either predicate
+}
+
+// returned by the scan once the limit is reached, so it stops reading.
+var errQueryDone = errors.New("query limit reached")
+
+// finds the records from <from> to <to> that get through filter. Unlike Get, the raw predicate runs before anything is
+// decoded, so records it turns down cost next to nothing, and the scan stops as soon as the limit is reached.
+func (ink *InkDB) Query(inksack string, from, to SplotchKey, filter QueryFilter) (QueryResult, error) {
+	return ink.QueryCtx(context.Background(), inksack, from, to, filter)
+}
+
+// the same as Query, but gives up between splotches and between records once ctx is done, returning ctx.Err().
+func (ink *InkDB) QueryCtx(ctx context.Context, inksack string, from, to SplotchKey, filter QueryFilter) (_ QueryResult, err error) {
+	result := QueryResult{}
+	sack, format, err := ink.getSackAndFormat(inksack)
+	if err != nil {
+		return result, err
+	}
+	start := time.Now()
+	defer func() {
+		sack.metrics.record(queryMetric, start, err)
+	}()
+	offset := filter.Offset
+	err = sack.scanStored(ctx, from, to, sack.splotchGetAll, func(item storedItem) error {
+		encoded, err := format.compression.decompress(item.Value)
+		if err != nil {
+			return err
+		}
+		if filter.Raw != nil && !filter.Raw(item.Key, encoded) {
+			result.Skipped++
+			return nil
+		}
+		value, err := format.decodeEncoded(encoded)
+		if err != nil {
+			return err
+		}
+		result.Decoded++
+		if filter.Value != nil && !filter.Value(item.Key, value) {
+			result.Skipped++
+			return nil
+		}
+		if offset > 0 {
+			offset--
+			return nil
+		}
+		result.Values = append(result.Values, value)
+		result.Keys = append(result.Keys, item.Key)
+		if filter.Limit > 0 && len(result.Keys) >= filter.Limit {
+			return errQueryDone
+		}
+		return nil
+	})
+	if err == errQueryDone {
+		err = nil
+	}
+	if err != nil {
+		return QueryResult{}, err
+	}
+	sack.metrics.add(queryRecordsMetric, float64(len(result.Keys)))
+	return result, nil
+}
@@ -0,0 +1,103 @@
+package inkdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkDBQuery(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink.Close()
+	if err := ink.NewTable("raw", []byte{}, WithCodec(rawCodec{}), WithRollover(RolloverPolicy{MaxRows: 3})); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("objects", &testableObject{}, WithRollover(RolloverPolicy{MaxRows: 3})); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := ink.Append("raw", []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := ink.Append("objects", testableObject{"object", i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//only the even ones get decoded.
+	even := func(key SplotchKey, raw []byte) bool { return raw[0]%2 == 0 }
+	result, err := ink.Query("raw", KeyFromUint64(0), MaxSplotchKey, QueryFilter{Raw: even})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []any{[]byte{0}, []byte{2}, []byte{4}, []byte{6}, []byte{8}}, result.Values)
+	assert.Equal(t, 5, result.Decoded)
+	assert.Equal(t, 5, result.Skipped)
+
+	//passes over the first two matches, and stops once it has two more.
+	result, err = ink.Query("raw", KeyFromUint64(0), MaxSplotchKey, QueryFilter{Raw: even, Offset: 2, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []SplotchKey{KeyFromUint64(5), KeyFromUint64(7)}, result.Keys)
+	assert.Equal(t, 4, result.Decoded)
+	assert.Equal(t, 3, result.Skipped)
+
+	big := func(key SplotchKey, value any) bool { return value.(*testableObject).IntVal >= 6 }
+	result, err = ink.Query("objects", KeyFromUint64(2), KeyFromUint64(8), QueryFilter{Value: big})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []any{&testableObject{"object", 6}, &testableObject{"object", 7}}, result.Values)
+	assert.Equal(t, 7, result.Decoded)
+	assert.Equal(t, 5, result.Skipped)
+
+	//tombstoned records aren't seen at all.
+	if err := ink.Tombstone("objects", KeyFromUint64(7)); err != nil {
+		t.Fatal(err)
+	}
+	result, err = ink.Query("objects", KeyFromUint64(0), MaxSplotchKey, QueryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, result.Keys, 9)
+	assert.Equal(t, 9, result.Decoded)
+	assert.Equal(t, 0, result.Skipped)
+
+	_, err = ink.Query("missing", KeyFromUint64(0), MaxSplotchKey, QueryFilter{})
+	assert.ErrorIs(t, err, ErrNoTable)
+}
+
+func TestInkDBQueryCompressed(t *testing.T) {
+	folder := getInkTestFile()
+	metrics := NewMetrics()
+	ink, err := NewInkDB(folder, WithMetrics(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink.Close()
+	if err := ink.NewTable("raw", []byte{}, WithCodec(rawCodec{}), WithCompression(GzipCompression)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := ink.Append("raw", []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//the raw predicate sees what the codec wrote, not the gzipped bytes on the disc.
+	even := func(key SplotchKey, raw []byte) bool { return len(raw) == 1 && raw[0]%2 == 0 }
+	result, err := ink.Query("raw", KeyFromUint64(0), MaxSplotchKey, QueryFilter{Raw: even})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []any{[]byte{0}, []byte{2}, []byte{4}, []byte{6}, []byte{8}}, result.Values)
+
+	//queries are counted on their own, not as gets.
+	assert.Equal(t, 1.0, metrics.Counter(queryMetric.total, "raw"))
+	assert.Equal(t, 5.0, metrics.Counter(queryRecordsMetric, "raw"))
+	assert.Equal(t, 0.0, metrics.Counter(getMetric.total, "raw"))
+}
@@ -0,0 +1,101 @@
+package inkdb
+
+import (
+	"fmt"
+	"path"
+)
+
+// changes how the whole database is setup.
+type DBOption func(*InkDB)
+
+// caps how many bytes of values the whole database can hold. Appends past it fail with ErrQuotaExceeded.
+func WithDBQuota(bytes int64) DBOption {
+	return func(ink *InkDB) {
+		ink.quotaBytes = bytes
+	}
+}
+
+// how much free space to always leave on each drive. Splotches and commits that would eat into it fail with ErrInsufficientSpace.
+func WithMinFreeSpace(bytes int64) DBOption {
+	return func(ink *InkDB) {
+		ink.minFreeSpace = bytes
+	}
+}
+
+// caps how many bytes of values this table can hold. Appends past it fail with ErrQuotaExceeded.
+func WithQuota(bytes int64) TableOption {
+	return func(md *sackMetadata) {
+		md.QuotaBytes = bytes
+	}
+}
+
+// checks that adding size more bytes won't take the whole database past its quota, and sets them aside so appends to
+// other tables at the same time can't be given them too. release has to be called once the append is done, whether it
+// worked or not. If it did, the bytes are counted by its sack from then on.
+func (ink *InkDB) reserveQuota(size int) (release func(), err error) {
+	if ink.quotaBytes <= 0 {
+		return func() {}, nil
+	}
+	ink.quotaMu.Lock()
+	defer ink.quotaMu.Unlock()
+	total := ink.reservedBytes + int64(size)
+	for _, sack := range ink.allSacks() {
+		sack.mu.Lock()
+		total += sack.usedBytes
+		sack.mu.Unlock()
+	}
+	if total > ink.quotaBytes {
+		return nil, fmt.Errorf("%w: the database is limited to %v bytes", ErrQuotaExceeded, ink.quotaBytes)
+	}
+	ink.reservedBytes += int64(size)
+	return func() {
+		ink.quotaMu.Lock()
+		ink.reservedBytes -= int64(size)
+		ink.quotaMu.Unlock()
+	}, nil
+}
+
+// the bytes of values held by the sack, committed or not.
+func (is *inkSack) bytesStored() int64 {
+	var total int64
+	for _, splotch := range is.inkSplotches {
+		total += splotch.headings.BytesStored
+	}
+	return total
+}
+
+// checks that adding size more bytes won't take the sack past its quota.
+func (is *inkSack) checkQuota(size int) error {
+	if is.metadata.QuotaBytes > 0 && is.usedBytes+int64(size) > is.metadata.QuotaBytes {
+		return fmt.Errorf("%w: inksack %v is limited to %v bytes", ErrQuotaExceeded, path.Base(is.localFilesLocation), is.metadata.QuotaBytes)
+	}
+	return nil
+}
+
+// makes sure every folder that's about to be written to has room for it, on top of the free space we've been told to leave.
+// needed is how many bytes are about to go into each folder.
+func (is *inkSack) checkSpace(needed map[string]int64) error {
+	for dir, size := range needed {
+		free, known := freeSpace(dir)
+		if !known {
+			//nothing we can check here, so just hope for the best.
+			continue
+		}
+		if int64(free) < size+is.minFreeSpace {
+			return fmt.Errorf("%w: %v needs %v bytes, but only %v are free", ErrInsufficientSpace, dir, size+is.minFreeSpace, free)
+		}
+	}
+	return nil
+}
+
+// a rough upper guess at how many bytes committing the splotch will write. Each item gets some room for its key, and the gob framing.
+func (splotch *inkSplotch) pendingBytes() int64 {
+	if len(splotch.unsavedItems) == 0 {
+		return 0
+	}
+	var size int64 = 512 //the segment's gob type info
+	for _, item := range splotch.unsavedItems {
+		size += int64(len(item.Value)) + 32
+	}
+	return size
+}
@@ -0,0 +1,130 @@
+package inkdb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkDBQuotas(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder, WithDBQuota(200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("small", []byte{}, WithCodec(rawCodec{}), WithQuota(50)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("big", []byte{}, WithCodec(rawCodec{})); err != nil {
+		t.Fatal(err)
+	}
+	tenBytes := []byte("0123456789")
+	for i := 0; i < 5; i++ {
+		if err := ink.Append("small", tenBytes); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//the table is full, but the database isn't.
+	assert.True(t, errors.Is(ink.Append("small", tenBytes), ErrQuotaExceeded))
+	for i := 0; i < 15; i++ {
+		if err := ink.Append("big", tenBytes); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.True(t, errors.Is(ink.Append("big", tenBytes), ErrQuotaExceeded))
+
+	usage := ink.Usage()
+	assert.Equal(t, int64(200), usage.Bytes)
+	assert.Equal(t, int64(200), usage.Quota)
+	assert.Equal(t, TableUsage{Bytes: 50, Quota: 50}, usage.Tables["small"])
+	assert.Equal(t, TableUsage{Bytes: 150}, usage.Tables["big"])
+
+	//usage should still be known after a restart.
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(200), ink2.Usage().Bytes)
+}
+
+func TestInkDBQuotaConcurrent(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder, WithDBQuota(200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink.Close()
+	tables := 8
+	for i := 0; i < tables; i++ {
+		if err := ink.NewTable(fmt.Sprintf("table%v", i), []byte{}, WithCodec(rawCodec{})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//every table racing for the same space shouldn't be able to take the database past its quota between them.
+	tenBytes := []byte("0123456789")
+	wg := sync.WaitGroup{}
+	for i := 0; i < tables*4; i++ {
+		wg.Add(1)
+		go func(table string) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := ink.Append(table, tenBytes); err != nil {
+					assert.ErrorIs(t, err, ErrQuotaExceeded)
+				}
+			}
+		}(fmt.Sprintf("table%v", i%tables))
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, ink.Usage().Bytes, int64(200))
+	//nothing is still held back once they're all done, so the last of the space can still be used.
+	assert.Equal(t, int64(0), ink.reservedBytes)
+}
+
+func TestInkDBInsufficientSpace(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder, WithMinFreeSpace(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	//no drive has this much room, so the very first splotch can't be made.
+	if _, known := freeSpace(folder); !known {
+		t.Skip("free space can't be checked here")
+	}
+	assert.True(t, errors.Is(ink.Append("table", generateTestableObject(0)), ErrInsufficientSpace))
+
+	//with space for the splotch, but not for the commit, nothing should be written.
+	sack := ink.inkSacks["table"]
+	sack.minFreeSpace = 0
+	if err := ink.Append("table", generateTestableObject(0)); err != nil {
+		t.Fatal(err)
+	}
+	sack.minFreeSpace = 1 << 62
+	assert.True(t, errors.Is(ink.Commit(), ErrInsufficientSpace))
+	assert.Equal(t, int64(splotchHeaderSize), sack.inkSplotches[0].headings.DataEnd)
+	assert.Equal(t, 1, len(sack.inkSplotches[0].unsavedItems))
+}
+
+// stores []byte values exactly as they are, so the sizes in tests are easy to work out.
+type rawCodec struct{}
+
+func init() {
+	RegisterCodec(rawCodec{})
+}
+
+func (rawCodec) Name() string { return "raw" }
+func (rawCodec) Encode(item any) ([]byte, error) {
+	return item.([]byte), nil
+}
+func (rawCodec) Decode(data []byte, into any) error {
+	*(into.(*[]byte)) = append([]byte{}, data...)
+	return nil
+}
@@ -0,0 +1,188 @@
+package inkdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"go.uber.org/zap"
+)
+
+// how many times to re-read a splotch's headings that didn't make sense. The writer might have been part way through
+// rewriting them.
+const headingsRetries = 3
+
+// opens the database at storing without ever writing to it, so it can sit alongside a process that is.
+// it doesn't take the writer's lock or create anything. Each read catches up with whatever has been committed since,
+// including tables that have been made since it was opened. Anything that would change the database fails with ErrReadOnly.
+func OpenReadOnly(storing string, opts ...DBOption) (*InkDB, error) {
+	idb := &InkDB{
+		fileStartPoint: storing,
+		inkSacks:       map[string]*inkSack{},
+		inkColors:      map[string]any{},
+		logger:         zap.NewNop(),
+		readOnly:       true,
+	}
+	for _, opt := range opts {
+		opt(idb)
+	}
+	//there's never anything to commit, or expire.
+	idb.flusher = nil
+	idb.maintenance = nil
+	if err := idb.loadTables(); err != nil {
+		idb.logger.Error("couldn't open database", zap.String("location", storing), zap.Error(err))
+		return nil, err
+	}
+	idb.logger.Info("opened database read only", zap.String("location", storing), zap.Int("tables", len(idb.inkSacks)))
+	return idb, nil
+}
+
+// returns ErrReadOnly or ErrClosed, if the database can't be changed.
+func (ink *InkDB) checkWritable() error {
+	if ink.readOnly {
+		return ErrReadOnly
+	}
+	return ink.checkOpen()
+}
+
+// for a read only database, finds the table called name and catches it up with what's been committed to it.
+// tables that have appeared since are loaded, and ones that have gone are forgotten.
+func (ink *InkDB) followTable(name string) (*inkSack, error) {
+	ink.mu.Lock()
+	if ink.closed {
+		ink.mu.Unlock()
+		return nil, ErrClosed
+	}
+	location := path.Join(ink.fileStartPoint, "inksacks", name)
+	if _, err := os.Stat(location); validTableName(name) != nil || err != nil {
+		//dropped or renamed by the writer.
+		delete(ink.inkSacks, name)
+		ink.mu.Unlock()
+		return nil, fmt.Errorf("%w under %v", ErrNoTable, name)
+	}
+	sack := ink.inkSacks[name]
+	if sack == nil {
+		defer ink.mu.Unlock()
+		opened, err := ink.openSack(name)
+		if err != nil {
+			return nil, err
+		}
+		ink.inkSacks[name] = opened
+		return opened, nil
+	}
+	ink.mu.Unlock()
+
+	if err := sack.lock(); err != nil {
+		return nil, err
+	}
+	defer sack.mu.Unlock()
+	return sack, sack.refresh()
+}
+
+// catches every table up, and picks up any new ones.
+func (ink *InkDB) followTables() {
+	entries, err := os.ReadDir(path.Join(ink.fileStartPoint, "inksacks"))
+	if err != nil {
+		return
+	}
+	found := map[string]bool{}
+	for _, entry := range entries {
+		if validTableName(entry.Name()) == nil {
+			found[entry.Name()] = true
+			ink.followTable(entry.Name())
+		}
+	}
+	ink.mu.Lock()
+	defer ink.mu.Unlock()
+	for name := range ink.inkSacks {
+		if !found[name] {
+			delete(ink.inkSacks, name)
+		}
+	}
+}
+
+// re-reads the manifest and the headings of any splotch that could have changed, so the sack matches what's been committed.
+// splotches that haven't changed keep whatever they have loaded.
+func (is *inkSack) refresh() error {
+	manifest, found, err := is.loadManifest()
+	if err != nil {
+		return err
+	}
+	if !found {
+		if manifest, err = is.legacyManifest(); err != nil {
+			return err
+		}
+	}
+	known := map[manifestEntry]*inkSplotch{}
+	var lastKnown *inkSplotch
+	for i, entry := range is.manifest.Splotches {
+		entry.Checksum, entry.Summed = 0, false
+		known[entry] = is.inkSplotches[i]
+		lastKnown = is.inkSplotches[i]
+	}
+	splotches := make([]*inkSplotch, len(manifest.Splotches))
+	for i, entry := range manifest.Splotches {
+		entry.Checksum, entry.Summed = 0, false
+		splotch := known[entry]
+		if splotch == nil {
+			if splotch, err = is.newSplotch(manifest.Splotches[i]); err != nil {
+				return err
+			}
+		} else if splotch == lastKnown {
+			//only the last one could have been added to. Everything before it was already full.
+			if err := splotch.reloadHeadings(); err != nil {
+				return err
+			}
+		}
+		splotches[i] = splotch
+	}
+	//the writer could be part way through committing the last splotch, so only the closed ones get checked.
+	for i, splotch := range splotches {
+		splotch.checksummed = false
+		if i != len(splotches)-1 && manifest.Splotches[i].hasChecksum() {
+			splotch.setChecksum(manifest.Splotches[i].Checksum)
+		}
+	}
+	if err := is.loadTombstones(); err != nil {
+		return err
+	}
+	is.manifest = manifest
+	is.inkSplotches = splotches
+	is.usedBytes = is.bytesStored()
+	is.largestKey = SplotchKey{}
+	if len(splotches) != 0 {
+		is.largestKey = splotches[len(splotches)-1].headings.LargestKey
+	}
+	return nil
+}
+
+// re-reads the headings from the file, in case another process has committed more to it since.
+// if it has, anything loaded is thrown away, to be loaded again when it's next needed.
+func (splotch *inkSplotch) reloadHeadings() error {
+	var err error
+	for attempt := 0; attempt < headingsRetries; attempt++ {
+		if err = splotch.checkHeadings(); err == nil || errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return err
+}
+
+func (splotch *inkSplotch) checkHeadings() error {
+	f, err := openSplotchFile(splotch.loadLocation())
+	if err != nil {
+		return err
+	}
+	headings := fileHeadings{}
+	_, err = readHeadings(f, &headings)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if headings.DataEnd == splotch.headings.DataEnd {
+		return nil
+	}
+	splotch.hasFullyLoaded = false
+	return splotch.PartialLoad()
+}
@@ -0,0 +1,110 @@
+package inkdb
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkDBReadOnly(t *testing.T) {
+	folder := getInkTestFile()
+	_, err := OpenReadOnly(folder)
+	assert.Error(t, err)
+	//nothing should have been made, just by trying.
+	_, err = os.Stat(folder)
+	assert.True(t, os.IsNotExist(err))
+
+	writer, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	if err := writer.NewTable("table", &testableObject{}, WithRollover(RolloverPolicy{MaxRows: 3})); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := writer.Append("table", generateTestableObject(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	//the writer still has its lock, but readers don't need it.
+	reader, err := OpenReadOnly(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if err := reader.NewTable("table", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	found, _, err := reader.Get("table", SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, found, 2)
+
+	//more is added, rolling over into new splotches. Only what's committed shows up.
+	for i := 2; i < 8; i++ {
+		if err := writer.Append("table", generateTestableObject(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found, _, _ = reader.Get("table", SplotchKey{}, MaxSplotchKey)
+	assert.Len(t, found, 2)
+	if err := writer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	found, keys, err := reader.Get("table", SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, found, 8)
+	for i := range found {
+		assert.Equal(t, generateTestableObject(i), found[i])
+		assert.Equal(t, KeyFromUint64(uint64(i+1)), keys[i])
+	}
+	stats, _ := reader.Stats("table")
+	assert.Equal(t, 8, stats.Records)
+	assert.Equal(t, 3, stats.Splotches)
+
+	//new tables turn up too, and dropped ones go.
+	if err := writer.NewTable("later", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Append("later", generateTestableObject(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	//it can be given its type before the reader has even looked for it. Its options are still the writer's to change.
+	assert.ErrorIs(t, reader.NewTable("later", &testableObject{}, WithRollover(RolloverPolicy{MaxRows: 3})), ErrReadOnly)
+	if err := reader.NewTable("later", &testableObject{}); err != nil {
+		t.Fatal(err)
+	}
+	found, _, err = reader.Get("later", SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []any{generateTestableObject(0)}, found)
+	assert.Equal(t, []string{"later", "table"}, reader.ListTables())
+	if err := writer.DropTable("later"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"table"}, reader.ListTables())
+
+	//and nothing can be changed through it.
+	assert.ErrorIs(t, reader.Append("table", generateTestableObject(0)), ErrReadOnly)
+	_, err = reader.AppendBatch("table", []any{generateTestableObject(0)})
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.ErrorIs(t, reader.Commit(), ErrReadOnly)
+	assert.ErrorIs(t, reader.NewTable("other", &testableObject{}), ErrReadOnly)
+	assert.ErrorIs(t, reader.TruncateTable("table"), ErrReadOnly)
+	_, err = os.Stat(path.Join(folder, "inksacks", "other"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,203 @@
+package inkdb
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// how often the maintenance runs if it isn't set.
+const defaultMaintenanceInterval = time.Minute
+
+// when the background maintenance runs, and who hears about it.
+type MaintenancePolicy struct {
+	Interval time.Duration         //how often to run. Once a minute if left at 0
+	OnRun    func(RetentionReport) //called after every run that removed anything
+	OnError  func(error)           //called whenever a run fails
+}
+
+// what enforcing the retention policies removed, by table. Tables that lost nothing are left out.
+type RetentionReport struct {
+	Tables map[string]RetentionRemoved
+}
+
+// what was removed from one table.
+type RetentionRemoved struct {
+	Splotches int
+	Records   int
+	Bytes     int64
+	UpTo      SplotchKey //the largest key removed. Everything up to it has gone.
+}
+
+// enforces every table's RetentionPolicy in the background, following policy.
+func WithMaintenance(policy MaintenancePolicy) DBOption {
+	return func(ink *InkDB) {
+		ink.maintenance = &maintenance{policy: policy}
+	}
+}
+
+// runs the maintenance every so often, until it's closed.
+type maintenance struct {
+	policy MaintenancePolicy
+
+	once sync.Once
+	stop chan struct{}
+	done chan struct{}
+}
+
+// starts the maintenance running for ink.
+func (m *maintenance) start(ink *InkDB) {
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go m.run(ink)
+}
+
+func (m *maintenance) run(ink *InkDB) {
+	defer close(m.done)
+	interval := m.policy.Interval
+	if interval <= 0 {
+		interval = defaultMaintenanceInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+		report, err := ink.EnforceRetention()
+		if errors.Is(err, ErrClosed) {
+			//Close is about to stop us anyway.
+			return
+		}
+		if err != nil {
+			ink.logger.Error("background maintenance failed", zap.Error(err))
+			if m.policy.OnError != nil {
+				m.policy.OnError(err)
+			}
+		}
+		if len(report.Tables) != 0 && m.policy.OnRun != nil {
+			m.policy.OnRun(report)
+		}
+	}
+}
+
+// stops the maintenance, waiting for a run part way through to finish. Fine to call on nil, and more than once.
+func (m *maintenance) close() {
+	if m == nil {
+		return
+	}
+	m.once.Do(func() {
+		close(m.stop)
+		<-m.done
+	})
+}
+
+// drops every whole splotch that has fallen outside of its table's RetentionPolicy. The splotch still being filled is
+// always kept, so a table can end up holding a little more than its policy asks for.
+func (ink *InkDB) EnforceRetention() (RetentionReport, error) {
+	report := RetentionReport{Tables: map[string]RetentionRemoved{}}
+	if err := ink.checkWritable(); err != nil {
+		return report, err
+	}
+	ink.commitMu.Lock()
+	defer ink.commitMu.Unlock()
+	sacks := ink.allSacks()
+	names := make([]string, 0, len(sacks))
+	for name := range sacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	now := time.Now()
+	for _, name := range names {
+		sack := sacks[name]
+		if err := sack.lock(); err != nil {
+			if errors.Is(err, ErrNoTable) {
+				//dropped since, so there's nothing left to expire.
+				continue
+			}
+			return report, err
+		}
+		removed, err := sack.enforceRetention(now)
+		sack.mu.Unlock()
+		if removed.Splotches != 0 {
+			report.Tables[name] = removed
+			sack.metrics.add(retentionRecordsMetric, float64(removed.Records))
+			sack.logger.Info("removed expired splotches", zap.Int("splotches", removed.Splotches),
+				zap.Int("records", removed.Records), zap.Int64("bytes", removed.Bytes))
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// drops the splotches that have expired. The manifest is changed first, then their files are removed.
+func (is *inkSack) enforceRetention(now time.Time) (RetentionRemoved, error) {
+	removed := RetentionRemoved{}
+	count := is.expiredSplotches(now)
+	if count == 0 {
+		return removed, nil
+	}
+	for _, splotch := range is.inkSplotches[:count] {
+		removed.Splotches++
+		removed.Records += splotch.headings.LinesStored
+		removed.Bytes += splotch.headings.BytesStored
+		removed.UpTo = splotch.headings.LargestKey
+	}
+	oldEntries := is.manifest.Splotches
+	is.manifest.Splotches = append([]manifestEntry{}, oldEntries[count:]...)
+	if err := is.saveManifest(); err != nil {
+		is.manifest.Splotches = oldEntries
+		return RetentionRemoved{}, err
+	}
+	is.manifestChanged = false
+	is.inkSplotches = is.inkSplotches[count:]
+	is.usedBytes -= removed.Bytes
+	if err := is.dropTombstones(func(key SplotchKey, _ int64) bool { return key.LessOrEqual(removed.UpTo) }); err != nil {
+		return removed, err
+	}
+
+	for _, entry := range oldEntries[:count] {
+		for _, location := range is.entryCopies(entry) {
+			if err := os.Remove(location); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return removed, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// how many splotches, from the oldest, have fallen outside the retention policy. A splotch goes once any one of the
+// limits says it can, but never the last one, as it's still being filled.
+func (is *inkSack) expiredSplotches(now time.Time) int {
+	policy := is.metadata.Retention
+	if policy == (RetentionPolicy{}) {
+		return 0
+	}
+	records, bytes := 0, int64(0)
+	for _, splotch := range is.inkSplotches {
+		records += splotch.headings.LinesStored
+		bytes += splotch.headings.BytesStored
+	}
+	count := 0
+	for ; count < len(is.inkSplotches)-1; count++ {
+		headings := is.inkSplotches[count].headings
+		expired := headings.LinesStored == 0 ||
+			(policy.KeepRecords > 0 && records-headings.LinesStored >= policy.KeepRecords) ||
+			(policy.KeepFor > 0 && now.Sub(time.Unix(0, headings.LastAppended)) > policy.KeepFor) ||
+			(policy.KeepBytes > 0 && bytes > policy.KeepBytes)
+		if !expired {
+			break
+		}
+		records -= headings.LinesStored
+		bytes -= headings.BytesStored
+	}
+	return count
+}
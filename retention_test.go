@@ -0,0 +1,110 @@
+package inkdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkDBRetention(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rollover := WithRollover(RolloverPolicy{MaxRows: 2})
+	if err := ink.NewTable("records", []byte{}, WithCodec(rawCodec{}), rollover, WithRetention(RetentionPolicy{KeepRecords: 4})); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("forever", []byte{}, WithCodec(rawCodec{}), rollover); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := ink.Append("records", []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := ink.Append("forever", []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ink.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := ink.EnforceRetention()
+	if err != nil {
+		t.Fatal(err)
+	}
+	//only whole splotches go, so as many as possible while still keeping at least 4.
+	assert.Equal(t, map[string]RetentionRemoved{
+		"records": {Splotches: 3, Records: 6, Bytes: 6, UpTo: KeyFromUint64(6)},
+	}, report.Tables)
+	_, keys, err := ink.Get("records", KeyFromUint64(0), KeyFromUint64(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []SplotchKey{KeyFromUint64(7), KeyFromUint64(8), KeyFromUint64(9), KeyFromUint64(10)}, keys)
+	_, keys, _ = ink.Get("forever", KeyFromUint64(0), KeyFromUint64(100))
+	assert.Len(t, keys, 10)
+
+	//nothing more to do.
+	report, err = ink.EnforceRetention()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, report.Tables)
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink2.Close()
+	if err := ink2.NewTable("records", []byte{}); err != nil {
+		t.Fatal(err)
+	}
+	stats, _ := ink2.Stats("records")
+	assert.Equal(t, 4, stats.Records)
+	assert.Equal(t, KeyFromUint64(7), stats.SmallestKey)
+}
+
+func TestInkDBMaintenance(t *testing.T) {
+	folder := getInkTestFile()
+	runs := make(chan RetentionReport, 10)
+	ink, err := NewInkDB(folder, WithMaintenance(MaintenancePolicy{
+		Interval: 10 * time.Millisecond,
+		OnRun:    func(report RetentionReport) { runs <- report },
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink.Close()
+	if err := ink.NewTable("bytes", []byte{}, WithCodec(rawCodec{}), WithRollover(RolloverPolicy{MaxRows: 1}),
+		WithRetention(RetentionPolicy{KeepBytes: 20})); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := ink.Append("bytes", []byte("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ink.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	//a run could land part way through the appends, so add them up.
+	removed := 0
+	for removed < 3 {
+		select {
+		case report := <-runs:
+			removed += report.Tables["bytes"].Records
+		case <-time.After(5 * time.Second):
+			t.Fatal("maintenance never ran")
+		}
+	}
+	assert.Equal(t, 3, removed)
+	usage, _ := ink.Stats("bytes")
+	assert.Equal(t, int64(20), usage.ValueBytes)
+}
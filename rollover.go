@@ -0,0 +1,45 @@
+package inkdb
+
+import "time"
+
+// decides when a splotch is closed off, and the next one started. Each limit is ignored while it's left at zero.
+// a completely empty policy falls back to MaxRowsPerSplotch, so tables behave the way they always have.
+type RolloverPolicy struct {
+	MaxRows  int           //close a splotch once it holds this many rows
+	MaxBytes int64         //close a splotch once the values in it add up to this many bytes
+	MaxAge   time.Duration //close a splotch once its first row is this old
+}
+
+// checks if a splotch with the given headings should stop taking new items.
+func (rp RolloverPolicy) isFull(headings fileHeadings, now time.Time) bool {
+	if rp == (RolloverPolicy{}) {
+		return headings.LinesStored >= MaxRowsPerSplotch
+	}
+	if rp.MaxRows > 0 && headings.LinesStored >= rp.MaxRows {
+		return true
+	}
+	if rp.MaxBytes > 0 && headings.BytesStored >= rp.MaxBytes {
+		return true
+	}
+	if rp.MaxAge > 0 && headings.LinesStored != 0 &&
+		now.Sub(time.Unix(0, headings.FirstAppended)) >= rp.MaxAge {
+		return true
+	}
+	return false
+}
+
+// checks if a closed splotch could hold everything in headings without going past the policy's limits.
+// age doesn't come into it, as only the splotch still being filled goes by age. A policy with no row or byte limit
+// falls back to MaxRowsPerSplotch, so everything doesn't end up in the one file.
+func (rp RolloverPolicy) canHold(headings fileHeadings) bool {
+	if rp.MaxRows == 0 && rp.MaxBytes == 0 {
+		return headings.LinesStored <= MaxRowsPerSplotch
+	}
+	if rp.MaxRows > 0 && headings.LinesStored > rp.MaxRows {
+		return false
+	}
+	if rp.MaxBytes > 0 && headings.BytesStored > rp.MaxBytes {
+		return false
+	}
+	return true
+}
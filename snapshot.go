@@ -0,0 +1,8 @@
+package inkdb
+
+// copies the whole database, as of its last Commit, into dest. Appends can carry on while it runs, but nothing
+// uncommitted makes it into the snapshot. dest ends up as a normal database folder, so NewInkDB can open it directly.
+// it's also a full backup, so BackupSince can take incremental backups on top of it.
+func (ink *InkDB) Snapshot(dest string) error {
+	return ink.backup(dest, nil)
+}
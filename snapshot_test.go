@@ -0,0 +1,80 @@
+package inkdb
+
+import (
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkDBSnapshot(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drive := path.Join(folder, "snapshotDrive")
+	os.RemoveAll(drive)
+	if err := ink.NewTable("local", &testableObject{}, WithRollover(RolloverPolicy{MaxRows: 10})); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("spread", &testableObject{}, WithRollover(RolloverPolicy{MaxRows: 10}), WithDataDirs(RoundRobin, drive)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 25; i++ {
+		ink.Append("local", generateTestableObject(i))
+		ink.Append("spread", generateTestableObject(i))
+	}
+	if err := ink.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	//none of these are committed, so they shouldn't show up.
+	for i := 25; i < 40; i++ {
+		ink.Append("local", generateTestableObject(i))
+	}
+
+	dest := path.Join(folder, "snapshot")
+	os.RemoveAll(dest)
+	//keep writing while the snapshot is taken.
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 40; i < 100; i++ {
+			ink.Append("local", generateTestableObject(i))
+		}
+	}()
+	if err := ink.Snapshot(dest); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	assert.Error(t, ink.Snapshot(dest), "shouldn't write over an existing snapshot")
+
+	//more changes to the original shouldn't reach the snapshot either.
+	if err := ink.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := NewInkDB(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"local", "spread"} {
+		if err := snapshot.NewTable(table, &testableObject{}); err != nil {
+			t.Fatal(err)
+		}
+		vals, _, err := snapshot.Get(table, SplotchKey{}, SplotchKey{}.Plus(1000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assert.Equal(t, 25, len(vals), table) {
+			for i, val := range vals {
+				assert.Equal(t, generateTestableObject(i), val)
+			}
+		}
+	}
+	//the snapshot holds everything itself, it doesn't lean on the other drive.
+	assert.Empty(t, snapshot.inkSacks["spread"].metadata.DataDirs)
+}
@@ -1,52 +1,119 @@
 package inkdb
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/gob"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 // this is kept as a variable instead of a constant for the sake of testing. Benchmarks scale each splotch larger than I might otherwise want.
+// it's also the fallback row limit for any table that hasn't been given its own RolloverPolicy.
 var MaxRowsPerSplotch int = 65535 //2^16-1
 
+// the headings live in a fixed size block at the start of every splotch file. That way they can be rewritten in place, without moving the items after them.
+const splotchHeaderSize = 1024
+
+// the header block starts with this, followed by the version of the layout the file is in.
+// files from before the header block are one long gob stream instead, and get migrated when they're opened.
+var splotchMagic = []byte("INKS")
+
+const splotchFormatVersion = 1
+
 // all of the item at the top of the file.
 type fileHeadings struct {
-	LargestKey  SplotchKey
-	LinesStored int
+	LargestKey    SplotchKey
+	LinesStored   int
+	BytesStored   int64 //the total size of all the values stored
+	FirstAppended int64 //unix nano time the first item was added
+	LastAppended  int64 //unix nano time the last item was added
+	DataEnd       int64 //how far into the file the committed items go. Anything past this was never finished being written.
 }
 
 // this is per folder. Holds all of the stored items, as well as their values. Can be generated from a file.
 type inkSplotch struct {
 	fileLocation   string //what file is this stored in
+	readLocation   string //where to load from instead, if the file has gone bad and a mirror is being used
 	storedItems    []*storedItem
 	smallestKey    SplotchKey //the smallest key added to this
 	headings       fileHeadings
 	unsavedItems   []*storedItem
 	hasFullyLoaded bool
+	rollover       RolloverPolicy //when this splotch counts as full
+	fileMode       os.FileMode    //permissions the file is created with. 0644 if left empty
+	syncOnSave     bool           //if the file should be synced to disc before SaveToFile returns
+	metrics        *tableMetrics  //where loads and saves are counted. Shared with the sack, and fine to leave nil
+	logger         *zap.Logger    //already carries the splotch's name. Logs nowhere if left nil
+	readOnly       bool           //never creates the file if it's missing
+	legacy         bool           //the file is from before the header block, so it can be read but not added to
+	listed         bool           //the manifest lists it, so a missing file is an error rather than a new splotch
+	checksum       uint32         //what the file should add up to, checked on every full load
+	checksummed    bool           //if there's a checksum to check. Cleared whenever the file is saved, until the mirrors catch up
 }
 
 func NewInkSplotch(fileLocation string) (*inkSplotch, error) {
-	splotch := &inkSplotch{
+	return openInkSplotch(&inkSplotch{
 		fileLocation: fileLocation,
+	})
+}
+
+// loads (or creates) the file for a splotch that has already had its settings filled in.
+func openInkSplotch(splotch *inkSplotch) (*inkSplotch, error) {
+	if splotch.logger == nil {
+		splotch.logger = zap.NewNop()
 	}
+	fileLocation := splotch.loadLocation()
 	//check that the file already exists.
 	if _, err := os.Stat(fileLocation); errors.Is(err, os.ErrNotExist) {
 		// it doesn't exist.
+		if splotch.readOnly {
+			return nil, err
+		}
+		if splotch.listed {
+			//making it again would quietly lose whatever was in it.
+			return nil, fmt.Errorf("splotch %v is listed in the manifest, but %w", fileLocation, err)
+		}
 		return splotch, splotch.SaveToFile()
 	} else if err == nil {
 		//file already exists. So we will try to load from it
-		return splotch, splotch.PartialLoad()
+		if err := splotch.PartialLoad(); err != nil {
+			return splotch, err
+		}
+		if splotch.legacy && !splotch.readOnly {
+			return splotch, splotch.migrateLegacy()
+		}
+		return splotch, nil
 	} else {
 		//some unknown error occurred
 		return nil, err
 	}
 }
 
+// what the file is known to add up to, as of the last commit.
+func (splotch *inkSplotch) setChecksum(sum uint32) {
+	splotch.checksum = sum
+	splotch.checksummed = true
+}
+
+// where the splotch should be loaded from. Normally its own file, unless that's been swapped for a mirror.
+func (splotch *inkSplotch) loadLocation() string {
+	if splotch.readLocation != "" {
+		return splotch.readLocation
+	}
+	return splotch.fileLocation
+}
+
 // checks if it still has space for more items to be added.
 func (splotch *inkSplotch) IsFull() bool {
-	return splotch.headings.LinesStored >= MaxRowsPerSplotch
+	return splotch.rollover.isFull(splotch.headings, time.Now())
 }
 
 // get the smallest and largest end currently stored
@@ -56,7 +123,7 @@ func (splotch *inkSplotch) GetEnds() (smallest, largest SplotchKey) {
 
 // take data, automatically create a key for it, and stores the data.
 func (splotch *inkSplotch) AutoAppend(value []byte) error {
-	if splotch.headings.LinesStored == MaxRowsPerSplotch {
+	if splotch.IsFull() {
 		return ErrSplotchFull
 	}
 	newKey := splotch.headings.LargestKey.NextKey()
@@ -65,12 +132,7 @@ func (splotch *inkSplotch) AutoAppend(value []byte) error {
 		Key:   newKey,
 		Value: value,
 	}
-	splotch.unsavedItems = append(splotch.unsavedItems, &fullData)
-	splotch.storedItems = append(splotch.storedItems, &fullData)
-	splotch.headings.LinesStored++
-	if splotch.headings.LinesStored == 1 {
-		splotch.smallestKey = newKey
-	}
+	splotch.addItem(&fullData)
 	return nil
 }
 
@@ -83,13 +145,22 @@ func (splotch *inkSplotch) Append(fullData storedItem) error {
 		return ErrSplotchRangeExceeded
 	}
 	splotch.headings.LargestKey = fullData.Key
-	splotch.unsavedItems = append(splotch.unsavedItems, &fullData)
-	splotch.storedItems = append(splotch.storedItems, &fullData)
+	splotch.addItem(&fullData)
+	return nil
+}
+
+// the shared bookkeeping for both kinds of append. The key should already be set as the largest.
+func (splotch *inkSplotch) addItem(fullData *storedItem) {
+	now := time.Now().UnixNano()
+	splotch.unsavedItems = append(splotch.unsavedItems, fullData)
+	splotch.storedItems = append(splotch.storedItems, fullData)
 	splotch.headings.LinesStored++
+	splotch.headings.BytesStored += int64(len(fullData.Value))
+	splotch.headings.LastAppended = now
 	if splotch.headings.LinesStored == 1 {
 		splotch.smallestKey = fullData.Key
+		splotch.headings.FirstAppended = now
 	}
-	return nil
 }
 
 // get a vale based on the key. Returns nil if none are found.
@@ -157,96 +228,132 @@ func (splotch *inkSplotch) SearchFor(lessThan func(storedItem) bool, equal func(
 
 // loads only the required elements for basic operations.
 func (splotch *inkSplotch) PartialLoad() error {
-	if _, err := os.Stat(splotch.fileLocation); err != nil {
+	if _, err := os.Stat(splotch.loadLocation()); err != nil {
 		//the file does not exist
 		return err
 	}
-	//if the file already exists, if it does, load this data. if not, create a new blank file for it.
-	f, err := os.OpenFile(splotch.fileLocation, os.O_RDONLY, 0666)
+	f, err := openSplotchFile(splotch.loadLocation())
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 
-	dec := gob.NewDecoder(f)
-	if err := dec.Decode(&splotch.headings); err != nil {
+	legacy, err := readHeadings(f, &splotch.headings)
+	if err != nil {
 		return err
 	}
-
-	var smallest storedItem
-	err = dec.Decode(&smallest)
-	if err == io.EOF {
-		//then there is no smallest yet, and we can return
-		return nil
-	}
+	splotch.legacy = legacy
+	//we only want the very first item, so just read the first segment.
+	items, err := readItems(f, splotch.headings, legacy, false)
 	if err != nil {
-		//a catch case for any other errors
 		return err
 	}
+	if len(items) == 0 {
+		//then there is no smallest yet, and we can return
+		return nil
+	}
+	smallest := items[0]
 	splotch.smallestKey = smallest.Key
-	splotch.storedItems = []*storedItem{&smallest}
-
-	//the file should consist of the largest key. Then line by line each item.
-	//then that data should be put into splotch
+	splotch.storedItems = []*storedItem{smallest}
 	return nil
 }
 
 // loads all of the data from disc into memory.
-func (splotch *inkSplotch) FullyLoad() error {
-	if _, err := os.Stat(splotch.fileLocation); err != nil {
+func (splotch *inkSplotch) FullyLoad() (err error) {
+	start := time.Now()
+	defer func() {
+		splotch.metrics.record(splotchLoadMetric, start, err)
+		if err != nil {
+			splotch.logger.Error("couldn't load splotch", zap.String("location", splotch.loadLocation()), zap.Error(err))
+		} else {
+			splotch.logger.Debug("loaded splotch", zap.Int("records", len(splotch.storedItems)), zap.Duration("took", time.Since(start)))
+		}
+	}()
+	if _, err := os.Stat(splotch.loadLocation()); err != nil {
 		//the file does not exist
 		return err
 	}
-	//if the file already exists, if it does, load this data. if not, create a new blank file for it.
-	f, err := os.OpenFile(splotch.fileLocation, os.O_RDONLY, 0666) //TODO: fix the permissions to be closer to what we need...
+	if splotch.checksummed {
+		sum, err := fileChecksum(splotch.loadLocation())
+		if err != nil {
+			return err
+		}
+		if sum != splotch.checksum {
+			return fmt.Errorf("%w: %v", ErrChecksumMismatch, splotch.loadLocation())
+		}
+	}
+	f, err := openSplotchFile(splotch.loadLocation())
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 
-	dec := gob.NewDecoder(f)
-	if err := dec.Decode(&splotch.headings); err != nil {
+	//keep hold of what we have in memory, the file only knows about what's been committed.
+	inMemory := splotch.headings
+	legacy, err := readHeadings(f, &splotch.headings)
+	if err != nil {
 		return err
 	}
-	splotch.storedItems = []*storedItem{}
-	for {
-		var nextValue storedItem
-		err := dec.Decode(&nextValue)
-		if err == io.EOF {
-			//we've read every item from the file.
-			break
-		}
-		if err != nil {
-			//we've hit an unexpected error
-			return err
-		}
-		splotch.storedItems = append(splotch.storedItems, &nextValue)
+	splotch.metrics.add(readBytesMetric, float64(splotch.headings.DataEnd))
+	if splotch.storedItems, err = readItems(f, splotch.headings, legacy, true); err != nil {
+		return err
 	}
-	//we now need to re-append all of the added values we already had (if any), and update the largest value in the header again.
+	//we now need to re-append all of the added values we already had (if any), and update the headings to match memory again.
 	splotch.storedItems = append(splotch.storedItems, splotch.unsavedItems...)
 	if len(splotch.unsavedItems) != 0 {
-		splotch.headings.LargestKey = splotch.storedItems[len(splotch.storedItems)-1].Key
+		dataEnd := splotch.headings.DataEnd
+		splotch.headings = inMemory
+		splotch.headings.DataEnd = dataEnd
 	}
 	splotch.hasFullyLoaded = true
 	return nil
 }
 
 // saves any changes from memory to the disc.
-func (splotch *inkSplotch) SaveToFile() error {
-	//first, we write the largest value we've found so far.
-	f, err := os.OpenFile(splotch.fileLocation, os.O_CREATE|os.O_WRONLY, 0644)
+func (splotch *inkSplotch) SaveToFile() (err error) {
+	start := time.Now()
+	defer func() {
+		splotch.metrics.record(splotchSaveMetric, start, err)
+		if err != nil {
+			splotch.logger.Error("couldn't save splotch", zap.String("location", splotch.fileLocation), zap.Error(err))
+		}
+	}()
+	fileMode := splotch.fileMode
+	if fileMode == 0 {
+		fileMode = 0644
+	}
+	f, err := os.OpenFile(splotch.fileLocation, os.O_CREATE|os.O_WRONLY, fileMode)
 	if err != nil {
 		return err
 	}
-	enc := gob.NewEncoder(f)
-	if err := enc.Encode(&splotch.headings); err != nil {
-		return err
+	if splotch.headings.DataEnd < splotchHeaderSize {
+		splotch.headings.DataEnd = splotchHeaderSize
 	}
-	_, err = f.Seek(0, 2) //go to the end of the file
-	if err != nil {
+	//whatever the file added up to before, it won't any more.
+	splotch.checksummed = false
+	//the new items go on as their own segment after whatever was committed before.
+	//they're written before the headings, so if we die part way through, the headings still point at the last good segment.
+	if len(splotch.unsavedItems) != 0 {
+		segment, err := encodeSegment(splotch.unsavedItems)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := f.WriteAt(segment, splotch.headings.DataEnd); err != nil {
+			f.Close()
+			return err
+		}
+		splotch.headings.DataEnd += int64(len(segment))
+		splotch.metrics.add(writtenBytesMetric, float64(len(segment)))
+	}
+	if err := writeHeadings(f, splotch.headings); err != nil {
+		f.Close()
 		return err
 	}
-	for _, item := range splotch.unsavedItems {
-
-		if err := enc.Encode(&item); err != nil {
+	splotch.metrics.add(writtenBytesMetric, splotchHeaderSize)
+	if splotch.syncOnSave {
+		if err := f.Sync(); err != nil {
+			f.Close()
 			return err
 		}
 	}
@@ -257,6 +364,10 @@ func (splotch *inkSplotch) SaveToFile() error {
 
 // get all of the storedItems from <from>, to <to>
 func (splotch *inkSplotch) GetAll(from, to SplotchKey) ([]storedItem, error) {
+	if splotch.headings.LinesStored == 0 {
+		//nothing in here yet. It may still have a largest key, carried on from the splotch before it.
+		return nil, ErrSplotchRangeExceeded
+	}
 	if from.GreaterThan(splotch.headings.LargestKey) || to.LessThan(splotch.smallestKey) {
 		//outside our range, no need to care.
 		return nil, ErrSplotchRangeExceeded
@@ -266,36 +377,220 @@ func (splotch *inkSplotch) GetAll(from, to SplotchKey) ([]storedItem, error) {
 			return nil, err
 		}
 	}
-	//this can be sped up by checking first if the range would fully contain this, start within but go on, start outside but finish within, or if it is fully contained, and handle it from there.
-	//if this is fully contained, then just return all items.
-	//if it just starts/stops here, find that point, and take the rest.
-	//if it's contained within this, find the start and end, and return that portion.
-	startIndex := 0
-	if from.GreaterThan(splotch.smallestKey) {
-		//it starts somewhere within us.
-		startIndex = BinarySearch(splotch.storedItems, func(item *storedItem) bool {
-			return item.Key.GreaterThan(from)
-		},
-			func(item *storedItem) bool {
-				return item.Key.Equal(from)
-			},
-		)
-	}
-	endIndex := len(splotch.storedItems) - 1
-	if to.LessThan(splotch.headings.LargestKey) {
-		//it ends within us.
-		endIndex = BinarySearch(splotch.storedItems, func(item *storedItem) bool {
-			return item.Key.GreaterThan(to)
-		},
-			func(item *storedItem) bool {
-				return item.Key.Equal(to)
-			},
-		)
-	}
+	//the ends of the range don't have to be keys that are actually stored, so look for where they'd go.
+	startIndex := sort.Search(len(splotch.storedItems), func(i int) bool {
+		return splotch.storedItems[i].Key.GreaterOrEqual(from)
+	})
+	endIndex := sort.Search(len(splotch.storedItems), func(i int) bool {
+		return splotch.storedItems[i].Key.GreaterThan(to)
+	})
 	foundItems := make([]storedItem, 0, endIndex-startIndex)
-	for i := startIndex; i <= endIndex; i++ {
+	for i := startIndex; i < endIndex; i++ {
 		//just do the iteration so we can convert the type
 		foundItems = append(foundItems, *splotch.storedItems[i])
 	}
 	return foundItems, nil
 }
+
+// writes the headings into the fixed block at the start of the file.
+func writeHeadings(f *os.File, headings fileHeadings) error {
+	buffer := &bytes.Buffer{}
+	buffer.Write(splotchMagic)
+	buffer.WriteByte(splotchFormatVersion)
+	buffer.Write(make([]byte, 4)) //room for the length
+	if err := gob.NewEncoder(buffer).Encode(&headings); err != nil {
+		return err
+	}
+	if buffer.Len() > splotchHeaderSize {
+		return fmt.Errorf("splotch headings too large to store")
+	}
+	block := make([]byte, splotchHeaderSize)
+	copy(block, buffer.Bytes())
+	lengthAt := len(splotchMagic) + 1
+	binary.BigEndian.PutUint32(block[lengthAt:], uint32(buffer.Len()-lengthAt-4))
+	_, err := f.WriteAt(block, 0)
+	return err
+}
+
+// reads the headings back out of the start of the file. Returns true if the file is from before the header block
+// (and so has no DataEnd), in which case its items have to be read with readItems.
+func readHeadings(r io.ReaderAt, headings *fileHeadings) (legacy bool, err error) {
+	block := make([]byte, splotchHeaderSize)
+	n, err := r.ReadAt(block, 0)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	block = block[:n]
+	switch {
+	case bytes.HasPrefix(block, splotchMagic) && n == splotchHeaderSize:
+		if version := block[len(splotchMagic)]; version != splotchFormatVersion {
+			return false, fmt.Errorf("splotch file is in format %v, this version of inkdb only reads up to %v", version, splotchFormatVersion)
+		}
+		block = block[len(splotchMagic)+1:]
+	case n == splotchHeaderSize && block[0] == 0 && block[1] == 0:
+		//a header block written before the magic was added. It starts straight in with the length.
+	default:
+		//one long gob stream, headings first. Its first byte is the length of a gob message, which is never 0.
+		*headings = fileHeadings{}
+		if err := gob.NewDecoder(io.NewSectionReader(r, 0, math.MaxInt64)).Decode(headings); err != nil {
+			return false, fmt.Errorf("splotch headings unreadable: %w", err)
+		}
+		headings.DataEnd = 0
+		return true, nil
+	}
+	size := binary.BigEndian.Uint32(block)
+	if size > uint32(len(block)-4) {
+		return false, fmt.Errorf("splotch headings corrupted")
+	}
+	return false, gob.NewDecoder(bytes.NewReader(block[4 : 4+size])).Decode(headings)
+}
+
+// reads the items out of a splotch file, in whichever layout readHeadings found it in. Only the first segment's worth
+// if not all.
+func readItems(r io.ReaderAt, headings fileHeadings, legacy, all bool) ([]*storedItem, error) {
+	items := []*storedItem{}
+	if legacy {
+		dec := gob.NewDecoder(io.NewSectionReader(r, 0, math.MaxInt64))
+		if err := dec.Decode(&fileHeadings{}); err != nil {
+			return nil, err
+		}
+		for {
+			var nextValue storedItem
+			err := dec.Decode(&nextValue)
+			if err == io.EOF {
+				return items, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, &nextValue)
+			if !all {
+				return items, nil
+			}
+		}
+	}
+	segments := newSegmentReader(r, headings.DataEnd)
+	for {
+		segment, err := segments.next()
+		if err == io.EOF {
+			//we've read every item from the file.
+			return items, nil
+		}
+		if err != nil {
+			//we've hit an unexpected error
+			return nil, err
+		}
+		items = append(items, segment...)
+		if !all {
+			return items, nil
+		}
+	}
+}
+
+// rewrites a file from before the header block in the current layout, so it can be appended to. The old file is only
+// replaced once the new one is completely written.
+func (splotch *inkSplotch) migrateLegacy() error {
+	info, err := os.Stat(splotch.fileLocation)
+	if err != nil {
+		return err
+	}
+	if err := splotch.FullyLoad(); err != nil {
+		return err
+	}
+	//the old layout didn't keep any of this, so it's made up from what's there.
+	headings := splotch.headings
+	headings.DataEnd = 0
+	headings.BytesStored = 0
+	for _, item := range splotch.storedItems {
+		headings.BytesStored += int64(len(item.Value))
+	}
+	headings.FirstAppended = info.ModTime().UnixNano()
+	headings.LastAppended = info.ModTime().UnixNano()
+	migrated := &inkSplotch{
+		fileLocation: splotch.fileLocation + ".tmp",
+		headings:     headings,
+		unsavedItems: splotch.storedItems,
+		fileMode:     splotch.fileMode,
+		syncOnSave:   true,
+		metrics:      splotch.metrics,
+		logger:       splotch.logger,
+	}
+	if err := os.Remove(migrated.fileLocation); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := migrated.SaveToFile(); err != nil {
+		return err
+	}
+	if err := os.Rename(migrated.fileLocation, splotch.fileLocation); err != nil {
+		return err
+	}
+	splotch.headings = migrated.headings
+	splotch.legacy = false
+	splotch.logger.Info("migrated splotch to the current file format", zap.Int("records", len(splotch.storedItems)))
+	return nil
+}
+
+// encodes a group of items as one segment. A segment is its length, followed by its own gob stream.
+func encodeSegment(items []*storedItem) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	buffer.Write(make([]byte, 4)) //room for the length
+	enc := gob.NewEncoder(buffer)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			return nil, err
+		}
+	}
+	segment := buffer.Bytes()
+	binary.BigEndian.PutUint32(segment, uint32(len(segment)-4))
+	return segment, nil
+}
+
+// walks through the segments of a splotch file, up to the end of the committed data.
+type segmentReader struct {
+	r      io.ReaderAt
+	offset int64
+	end    int64
+}
+
+func newSegmentReader(r io.ReaderAt, dataEnd int64) *segmentReader {
+	return &segmentReader{
+		r:      r,
+		offset: splotchHeaderSize,
+		end:    dataEnd,
+	}
+}
+
+// returns the items in the next segment, or io.EOF once there are no more.
+func (sr *segmentReader) next() ([]*storedItem, error) {
+	if sr.offset+4 > sr.end {
+		return nil, io.EOF
+	}
+	sizeBytes := make([]byte, 4)
+	if _, err := sr.r.ReadAt(sizeBytes, sr.offset); err != nil {
+		return nil, err
+	}
+	size := int64(binary.BigEndian.Uint32(sizeBytes))
+	if sr.offset+4+size > sr.end {
+		return nil, fmt.Errorf("splotch segment runs past the committed data")
+	}
+	segment := make([]byte, size)
+	if _, err := sr.r.ReadAt(segment, sr.offset+4); err != nil {
+		return nil, err
+	}
+	sr.offset += 4 + size
+
+	items := []*storedItem{}
+	dec := gob.NewDecoder(bytes.NewReader(segment))
+	for {
+		var nextValue storedItem
+		err := dec.Decode(&nextValue)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &nextValue)
+	}
+	return items, nil
+}
@@ -1,9 +1,17 @@
 package inkdb
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
 
 type SplotchKey [8]byte //a 64 bit index string
 
+// the largest key there can be. Handy as the <to> end of a range that should cover everything.
+var MaxSplotchKey = SplotchKey{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
 // lets say the keys are represented in big endian order.
 
 // returns a<b
@@ -55,6 +63,18 @@ func (a SplotchKey) Equal(b SplotchKey) bool {
 	return true
 }
 
+// the key as a plain number, the same order the keys sort in.
+func (k SplotchKey) Uint64() uint64 {
+	return binary.BigEndian.Uint64(k[:])
+}
+
+// makes the key for a plain number. The opposite of SplotchKey.Uint64
+func KeyFromUint64(n uint64) SplotchKey {
+	var k SplotchKey
+	binary.BigEndian.PutUint64(k[:], n)
+	return k
+}
+
 // generates the next incremental key
 func (k SplotchKey) NextKey() SplotchKey {
 	return k.Plus(1)
@@ -65,3 +85,61 @@ func (k SplotchKey) Plus(a int) SplotchKey {
 	//tbh, I'm not entirely sure why I wrote the keys as byte arrays instead of uint64s... Originally i was going to make them more flexible, in length, but decided to change that...
 	return SplotchKey(binary.BigEndian.AppendUint64([]byte{}, binary.BigEndian.Uint64(k[:])+uint64(a)))
 }
+
+// picks the key for each automatically appended item. Keys must always go up, so NextKey is given the largest key used so far.
+// the name is what gets saved with the table, so it needs to stay the same between runs.
+type KeyGenerator interface {
+	Name() string
+	NextKey(largest SplotchKey) SplotchKey
+}
+
+// the default generator, just counts up by one.
+type SequentialKeys struct{}
+
+func (SequentialKeys) Name() string { return "sequential" }
+func (SequentialKeys) NextKey(largest SplotchKey) SplotchKey {
+	return largest.NextKey()
+}
+
+// uses the unix nano time as the key. Two items in the same nanosecond (or a clock going backwards) just get the next key along.
+type TimestampKeys struct{}
+
+func (TimestampKeys) Name() string { return "timestamp" }
+func (TimestampKeys) NextKey(largest SplotchKey) SplotchKey {
+	var now SplotchKey
+	binary.BigEndian.PutUint64(now[:], uint64(time.Now().UnixNano()))
+	if now.GreaterThan(largest) {
+		return now
+	}
+	return largest.NextKey()
+}
+
+var (
+	keyGeneratorsMu sync.RWMutex
+	keyGenerators   = map[string]KeyGenerator{
+		SequentialKeys{}.Name(): SequentialKeys{},
+		TimestampKeys{}.Name():  TimestampKeys{},
+	}
+)
+
+// makes a key generator available to tables by its name. Like codecs, it needs registering before WithKeyGenerator can
+// use it, and again after a restart.
+func RegisterKeyGenerator(generator KeyGenerator) {
+	keyGeneratorsMu.Lock()
+	defer keyGeneratorsMu.Unlock()
+	keyGenerators[generator.Name()] = generator
+}
+
+// finds the generator saved under name. An empty name is the default sequential one.
+func lookupKeyGenerator(name string) (KeyGenerator, error) {
+	if name == "" {
+		return SequentialKeys{}, nil
+	}
+	keyGeneratorsMu.RLock()
+	generator, ok := keyGenerators[name]
+	keyGeneratorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no key generator registered under %v", name)
+	}
+	return generator, nil
+}
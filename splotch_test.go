@@ -1,6 +1,7 @@
 package inkdb
 
 import (
+	"encoding/gob"
 	"fmt"
 	"math/rand"
 	"os"
@@ -174,3 +175,140 @@ func BenchmarkSplotchStoringBulkToDisc(b *testing.B) {
 		b.StopTimer()
 	}
 }
+
+func TestInkSplotchSaveToFileRepeatedly(t *testing.T) {
+	fileLocation := getSplotchTestFile()
+	MaxRowsPerSplotch = 1000
+	splotch, err := NewInkSplotch(fileLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//commit a few times, with a growing amount each time, so the headings change size.
+	added := 0
+	for round := 1; round <= 4; round++ {
+		for i := 0; i < round*100; i++ {
+			if err := splotch.AutoAppend(getBasicPlaceholder(added)); err != nil {
+				t.Fatal(err)
+			}
+			added++
+		}
+		if err := splotch.SaveToFile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	splotch2, err := NewInkSplotch(fileLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, added, splotch2.headings.LinesStored)
+	items, err := splotch2.GetAll(SplotchKey{}, splotch2.headings.LargestKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Equal(t, added, len(items)) {
+		for i, item := range items {
+			assert.Equal(t, getBasicPlaceholder(i), item.Value)
+		}
+	}
+}
+
+func TestSplotchGetAllBetweenKeys(t *testing.T) {
+	splotch, err := NewInkSplotch(getSplotchTestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	//only the even keys are stored, so some ends of the ranges below fall in the gaps.
+	for _, key := range []uint64{2, 4, 6, 8} {
+		if err := splotch.Append(storedItem{Key: KeyFromUint64(key), Value: getBasicPlaceholder(int(key))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		from, to uint64
+		want     []uint64
+	}{
+		{4, 6, []uint64{4, 6}},
+		{3, 7, []uint64{4, 6}},
+		{0, 2, []uint64{2}},
+		{7, 100, []uint64{8}},
+		{0, 100, []uint64{2, 4, 6, 8}},
+		{5, 5, []uint64{}},
+	} {
+		items, err := splotch.GetAll(KeyFromUint64(test.from), KeyFromUint64(test.to))
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys := []uint64{}
+		for _, item := range items {
+			keys = append(keys, item.Key.Uint64())
+		}
+		assert.Equal(t, test.want, keys, "from %v to %v", test.from, test.to)
+	}
+}
+
+func TestInkSplotchLegacyFormat(t *testing.T) {
+	fileLocation := getSplotchTestFile()
+	//written the way splotches were before the header block: one gob stream, headings first.
+	f, err := os.Create(fileLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := gob.NewEncoder(f)
+	if err := enc.Encode(&struct {
+		LargestKey  SplotchKey
+		LinesStored int
+	}{KeyFromUint64(3), 3}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		if err := enc.Encode(&storedItem{Key: KeyFromUint64(uint64(i)), Value: getBasicPlaceholder(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+
+	//readers can't write, so they read it as it is.
+	reader, err := openInkSplotch(&inkSplotch{fileLocation: fileLocation, readOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, KeyFromUint64(1), reader.smallestKey)
+	items, err := reader.GetAll(KeyFromUint64(0), KeyFromUint64(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, items, 3)
+
+	//anything else moves it over to the current layout, and can carry on adding to it.
+	splotch, err := NewInkSplotch(fileLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, splotch.legacy)
+	assert.Equal(t, int64(len(getBasicPlaceholder(1))*3), splotch.headings.BytesStored)
+	if err := splotch.AutoAppend(getBasicPlaceholder(4)); err != nil {
+		t.Fatal(err)
+	}
+	if err := splotch.SaveToFile(); err != nil {
+		t.Fatal(err)
+	}
+	start := make([]byte, len(splotchMagic))
+	reread, _ := os.Open(fileLocation)
+	reread.Read(start)
+	reread.Close()
+	assert.Equal(t, splotchMagic, start)
+
+	splotch2, err := NewInkSplotch(fileLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err = splotch2.GetAll(KeyFromUint64(0), KeyFromUint64(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, items, 4)
+	for i, item := range items {
+		assert.Equal(t, KeyFromUint64(uint64(i+1)), item.Key)
+		assert.Equal(t, getBasicPlaceholder(i+1), item.Value)
+	}
+}
@@ -0,0 +1,128 @@
+package inkdb
+
+import "os"
+
+// how much one table is holding, against its quota.
+type TableUsage struct {
+	Bytes int64 //bytes of values held, committed or not
+	Quota int64 //0 if there isn't one
+}
+
+// how much the whole database is holding, against its quota.
+type DBUsage struct {
+	Bytes  int64 //bytes of values held across every table
+	Quota  int64 //0 if there isn't one
+	Tables map[string]TableUsage
+}
+
+// reports how much is being stored, and how close each table (and the database) is to its quota.
+func (ink *InkDB) Usage() DBUsage {
+	usage := DBUsage{
+		Quota:  ink.quotaBytes,
+		Tables: map[string]TableUsage{},
+	}
+	for name, sack := range ink.allSacks() {
+		sack.mu.Lock()
+		usage.Tables[name] = TableUsage{
+			Bytes: sack.usedBytes,
+			Quota: sack.metadata.QuotaBytes,
+		}
+		usage.Bytes += sack.usedBytes
+		sack.mu.Unlock()
+	}
+	return usage
+}
+
+// a summary of what a table is holding, and how much of it is in memory.
+type TableStats struct {
+	Records            int        //every record, committed or not
+	UncommittedRecords int        //records that haven't reached the disc yet
+	Tombstones         int        //records hidden by a tombstone, that Compact hasn't dropped yet. They still count in Records
+	Splotches          int        //how many splotch files the table is spread over
+	LoadedSplotches    int        //how many of those are fully loaded into memory
+	SmallestKey        SplotchKey //zero if the table is empty
+	LargestKey         SplotchKey //zero if the table is empty
+	ValueBytes         int64      //the size of every value added together
+	AverageValueSize   float64
+	BytesOnDisk        int64 //every file the table has, including mirrors
+}
+
+// the stats for every table, and the totals across all of them.
+type DBStats struct {
+	Records     int
+	Splotches   int
+	ValueBytes  int64
+	BytesOnDisk int64
+	Tables      map[string]TableStats
+}
+
+// reports what's stored in the given table.
+func (ink *InkDB) Stats(table string) (TableStats, error) {
+	sack, err := ink.getSack(table)
+	if err != nil {
+		return TableStats{}, err
+	}
+	if err := sack.lock(); err != nil {
+		return TableStats{}, err
+	}
+	defer sack.mu.Unlock()
+	return sack.stats(), nil
+}
+
+// reports what's stored in every table, along with the totals for the whole database.
+func (ink *InkDB) AllStats() DBStats {
+	if ink.readOnly {
+		ink.followTables()
+	}
+	stats := DBStats{Tables: map[string]TableStats{}}
+	for name, sack := range ink.allSacks() {
+		if sack.lock() != nil {
+			//dropped since we looked.
+			continue
+		}
+		tableStats := sack.stats()
+		sack.mu.Unlock()
+		stats.Tables[name] = tableStats
+		stats.Records += tableStats.Records
+		stats.Splotches += tableStats.Splotches
+		stats.ValueBytes += tableStats.ValueBytes
+		stats.BytesOnDisk += tableStats.BytesOnDisk
+	}
+	return stats
+}
+
+// works out the stats for the sack. Everything but the disc usage comes from what's already in memory.
+func (is *inkSack) stats() TableStats {
+	stats := TableStats{Splotches: len(is.inkSplotches), Tombstones: len(is.tombstones)}
+	for i, splotch := range is.inkSplotches {
+		stats.Records += splotch.headings.LinesStored
+		stats.UncommittedRecords += len(splotch.unsavedItems)
+		stats.ValueBytes += splotch.headings.BytesStored
+		if splotch.hasFullyLoaded {
+			stats.LoadedSplotches++
+		}
+		if splotch.headings.LinesStored != 0 {
+			if stats.Records == splotch.headings.LinesStored {
+				stats.SmallestKey = splotch.smallestKey
+			}
+			stats.LargestKey = splotch.headings.LargestKey
+		}
+		for _, location := range is.entryCopies(is.manifest.Splotches[i]) {
+			stats.BytesOnDisk += fileSize(location)
+		}
+	}
+	if stats.Records != 0 {
+		stats.AverageValueSize = float64(stats.ValueBytes) / float64(stats.Records)
+	}
+	stats.BytesOnDisk += fileSize(is.metadataLocation()) + fileSize(is.manifestLocation())
+	return stats
+}
+
+// the size of the file at location, or 0 if it isn't there.
+func fileSize(location string) int64 {
+	info, err := os.Stat(location)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
@@ -0,0 +1,69 @@
+package inkdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkDBStats(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("table", []byte{}, WithCodec(rawCodec{}), WithRollover(RolloverPolicy{MaxRows: 4})); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("empty", []byte{}, WithCodec(rawCodec{})); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := ink.Append("table", []byte("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err := ink.Stats("table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 10, stats.Records)
+	assert.Equal(t, 10, stats.UncommittedRecords)
+	assert.Equal(t, 3, stats.Splotches)
+	assert.Equal(t, int64(100), stats.ValueBytes)
+	assert.Equal(t, 10.0, stats.AverageValueSize)
+	assert.Equal(t, KeyFromUint64(1), stats.SmallestKey)
+	assert.Equal(t, KeyFromUint64(10), stats.LargestKey)
+
+	if err := ink.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	stats, _ = ink.Stats("table")
+	assert.Equal(t, 0, stats.UncommittedRecords)
+	assert.Less(t, int64(100), stats.BytesOnDisk)
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//after a restart nothing has been loaded, but the counts come from the headings.
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted, _ := ink2.Stats("table")
+	assert.Equal(t, 10, restarted.Records)
+	assert.Equal(t, 0, restarted.LoadedSplotches)
+	assert.Equal(t, stats.BytesOnDisk, restarted.BytesOnDisk)
+
+	empty, _ := ink2.Stats("empty")
+	assert.Equal(t, 0, empty.Records)
+	assert.Equal(t, 0.0, empty.AverageValueSize)
+
+	all := ink2.AllStats()
+	assert.Equal(t, 10, all.Records)
+	assert.Len(t, all.Tables, 2)
+	assert.Equal(t, restarted.BytesOnDisk+empty.BytesOnDisk, all.BytesOnDisk)
+
+	_, err = ink2.Stats("missing")
+	assert.ErrorIs(t, err, ErrNoTable)
+}
@@ -0,0 +1,238 @@
+package inkdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// checks the name can be used as a table's folder name.
+func validTableName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("%q can't be used as a table name", name)
+	}
+	return nil
+}
+
+// the names of every table, in order.
+func (ink *InkDB) ListTables() []string {
+	if ink.readOnly {
+		ink.followTables()
+	}
+	ink.mu.RLock()
+	defer ink.mu.RUnlock()
+	names := make([]string, 0, len(ink.inkSacks))
+	for name := range ink.inkSacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// removes a table, and everything stored in it.
+// the folder is renamed out of the way first, so a crash part way through deleting it can't leave half a table behind.
+func (ink *InkDB) DropTable(name string) error {
+	if err := ink.checkWritable(); err != nil {
+		return err
+	}
+	folders, err := ink.detachTable(name)
+	if err != nil {
+		return err
+	}
+	ink.logger.Info("dropped table", zap.String("table", name))
+
+	//nothing can reach the table anymore, so the deleting can happen without holding anything up.
+	for _, folder := range folders {
+		if err := os.RemoveAll(folder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// takes a table out of the database, and renames its folders out of the way. Returns every folder left to be deleted.
+func (ink *InkDB) detachTable(name string) ([]string, error) {
+	ink.commitMu.Lock()
+	defer ink.commitMu.Unlock()
+	ink.mu.Lock()
+	defer ink.mu.Unlock()
+	if ink.closed {
+		return nil, ErrClosed
+	}
+	sack := ink.inkSacks[name]
+	if sack == nil {
+		return nil, fmt.Errorf("%w under %v", ErrNoTable, name)
+	}
+	sack.mu.Lock()
+	defer sack.mu.Unlock()
+
+	//the folders on other drives are moved too, so a new table with the same name can't lose its folders to this one.
+	droppingName := fmt.Sprintf(".dropping-%v-%v", name, time.Now().UnixNano())
+	folders := []string{}
+	for _, dir := range sack.otherFolders() {
+		if err := os.Rename(path.Join(dir, name), path.Join(dir, droppingName)); errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			//put back what we can, so the table still works.
+			for _, folder := range folders {
+				os.Rename(folder, path.Join(path.Dir(folder), name))
+			}
+			return nil, err
+		}
+		folders = append(folders, path.Join(dir, droppingName))
+	}
+	dropping := path.Join(ink.fileStartPoint, "inksacks", droppingName)
+	if err := os.Rename(sack.localFilesLocation, dropping); err != nil {
+		for _, folder := range folders {
+			os.Rename(folder, path.Join(path.Dir(folder), name))
+		}
+		return nil, err
+	}
+	//from here on the table is gone, as far as anyone is concerned.
+	sack.dropped = true
+	delete(ink.inkSacks, name)
+	delete(ink.inkColors, name)
+	return append([]string{dropping}, folders...), nil
+}
+
+// moves a table to a new name. Anything already holding the old name just won't find it anymore.
+func (ink *InkDB) RenameTable(from, to string) error {
+	if err := ink.checkWritable(); err != nil {
+		return err
+	}
+	if err := validTableName(to); err != nil {
+		return err
+	}
+	ink.commitMu.Lock()
+	defer ink.commitMu.Unlock()
+	ink.mu.Lock()
+	defer ink.mu.Unlock()
+	if ink.closed {
+		return ErrClosed
+	}
+	sack := ink.inkSacks[from]
+	if sack == nil {
+		return fmt.Errorf("%w under %v", ErrNoTable, from)
+	}
+	if ink.inkSacks[to] != nil {
+		return fmt.Errorf("inksack %v already exists", to)
+	}
+	newLocation := path.Join(ink.fileStartPoint, "inksacks", to)
+	if _, err := os.Stat(newLocation); err == nil {
+		return fmt.Errorf("something is already stored at %v", newLocation)
+	}
+	sack.mu.Lock()
+	defer sack.mu.Unlock()
+
+	//the table's folders on any other drives go first. They're named after the table too.
+	moved := []string{}
+	for _, dir := range sack.otherFolders() {
+		if err := os.Rename(path.Join(dir, from), path.Join(dir, to)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			//put back what we can, so the table still works under its old name.
+			for _, movedDir := range moved {
+				os.Rename(path.Join(movedDir, to), path.Join(movedDir, from))
+			}
+			return err
+		}
+		moved = append(moved, dir)
+	}
+	if err := os.Rename(sack.localFilesLocation, newLocation); err != nil {
+		for _, movedDir := range moved {
+			os.Rename(path.Join(movedDir, to), path.Join(movedDir, from))
+		}
+		return err
+	}
+	sack.localFilesLocation = newLocation
+	sack.metrics.rename(to)
+	sack.logger = ink.tableLogger(to)
+	sack.relocateSplotches()
+	ink.logger.Info("renamed table", zap.String("table", from), zap.String("to", to))
+
+	ink.inkSacks[to] = sack
+	ink.inkColors[to] = ink.inkColors[from]
+	delete(ink.inkSacks, from)
+	delete(ink.inkColors, from)
+	return nil
+}
+
+// removes everything stored in a table, but keeps the table and its options. Keys carry on from where they were,
+// so nothing new ever gets a key that something removed had.
+func (ink *InkDB) TruncateTable(name string) error {
+	if err := ink.checkWritable(); err != nil {
+		return err
+	}
+	sack, err := ink.getSack(name)
+	if err != nil {
+		return err
+	}
+	ink.commitMu.Lock()
+	defer ink.commitMu.Unlock()
+	if err := sack.lock(); err != nil {
+		return err
+	}
+	defer sack.mu.Unlock()
+	if err := sack.truncate(); err != nil {
+		return err
+	}
+	ink.logger.Info("truncated table", zap.String("table", name))
+	return nil
+}
+
+// swaps every splotch for a single empty one. The new splotch and manifest are saved first, and only then is
+// anything swapped out, so a failure part way leaves the table as it was. The old files are removed last.
+func (is *inkSack) truncate() error {
+	manifest := sackManifest{NextSplotch: is.manifest.NextSplotch}
+	empty, entry, err := is.createSplotch(&manifest)
+	if err != nil {
+		return err
+	}
+	manifest.Splotches = []manifestEntry{entry}
+	empty.headings.LargestKey = is.largestKey
+	if err := empty.SaveToFile(); err != nil {
+		os.Remove(empty.fileLocation)
+		return err
+	}
+	old := is.manifest
+	is.manifest = manifest
+	if err := is.saveManifest(); err != nil {
+		is.manifest = old
+		os.Remove(empty.fileLocation)
+		return err
+	}
+	is.inkSplotches = []*inkSplotch{empty}
+	is.manifestChanged = false
+	is.usedBytes = 0
+	//every key they could hide has gone.
+	if err := is.dropTombstones(func(SplotchKey, int64) bool { return true }); err != nil {
+		return err
+	}
+
+	for _, entry := range old.Splotches {
+		for _, location := range is.entryCopies(entry) {
+			if err := os.Remove(location); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// every folder outside of the sack's own that it keeps a folder (named after the table) in.
+func (is *inkSack) otherFolders() []string {
+	return append(append(append([]string{}, is.metadata.DataDirs...), is.metadata.Mirrors...), is.metadata.ArchiveDirs...)
+}
+
+// points every splotch back at where its manifest entry says it is, after the sack has moved.
+func (is *inkSack) relocateSplotches() {
+	for i, entry := range is.manifest.Splotches {
+		is.inkSplotches[i].fileLocation = is.entryLocation(entry)
+		is.inkSplotches[i].readLocation = ""
+		is.inkSplotches[i].logger = is.logger.With(zap.String("splotch", entry.Name))
+	}
+}
@@ -0,0 +1,179 @@
+package inkdb
+
+import (
+	"errors"
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkDBTableManagement(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drive, mirror := path.Join(folder, "tablesDrive"), path.Join(folder, "tablesMirror")
+	os.RemoveAll(drive)
+	os.RemoveAll(mirror)
+	rollover := WithRollover(RolloverPolicy{MaxRows: 10})
+	if err := ink.NewTable("keep", &testableObject{}, rollover); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("move", &testableObject{}, rollover, WithDataDirs(RoundRobin, drive), WithMirrors(mirror)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("drop", &testableObject{}, rollover, WithDataDirs(RoundRobin, drive)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, ink.NewTable("../escape", &testableObject{}))
+	for i := 0; i < 25; i++ {
+		for _, table := range []string{"keep", "move", "drop"} {
+			if err := ink.Append(table, generateTestableObject(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := ink.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"drop", "keep", "move"}, ink.ListTables())
+
+	//the other tables should carry on working while these change.
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 25; i < 200; i++ {
+			if err := ink.Append("keep", generateTestableObject(i)); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	held, _ := ink.getSack("drop")
+	if err := ink.DropTable("drop"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(path.Join(folder, "inksacks", "drop"))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	_, err = os.Stat(path.Join(drive, "drop"))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	//nothing left behind on the other drive either.
+	leftover, err := os.ReadDir(drive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(leftover))
+	assert.True(t, errors.Is(ink.Append("drop", generateTestableObject(0)), ErrNoTable))
+	assert.True(t, errors.Is(held.lock(), ErrNoTable), "anything still holding the table should be turned away")
+
+	if err := ink.RenameTable("move", "moved"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, ink.RenameTable("moved", "keep"), "can't rename over another table")
+	vals, _, err := ink.Get("moved", SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 25, len(vals))
+	if err := ink.Append("moved", generateTestableObject(25)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ink.TruncateTable("moved"); err != nil {
+		t.Fatal(err)
+	}
+	vals, _, err = ink.Get("moved", SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, vals)
+	//keys carry on where they were.
+	if err := ink.Append("moved", generateTestableObject(0)); err != nil {
+		t.Fatal(err)
+	}
+	_, keys, err := ink.Get("moved", SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []SplotchKey{SplotchKey{}.Plus(27)}, keys)
+	wg.Wait()
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//and it should all still be that way after a restart.
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"keep", "moved"}, ink2.ListTables())
+	ink2.NewTable("keep", &testableObject{})
+	ink2.NewTable("moved", &testableObject{})
+	vals, _, err = ink2.Get("keep", SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, len(vals))
+	vals, keys, err = ink2.Get("moved", SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []any{generateTestableObject(0)}, vals)
+	assert.Equal(t, []SplotchKey{SplotchKey{}.Plus(27)}, keys)
+}
+
+func TestInkSackTruncateFailed(t *testing.T) {
+	folder := getSackTestFolder()
+	is, err := NewInkSack(folder, WithRollover(RolloverPolicy{MaxRows: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := is.AutoAppend([]byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := is.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	//the manifest can't be saved with a folder in the way of its temp file.
+	if err := os.Mkdir(is.manifestLocation()+".tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, is.truncate())
+	assert.Equal(t, 3, len(is.inkSplotches))
+	items, err := is.GetAll(SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 5, len(items))
+	files, err := os.ReadDir(is.splotchFolder(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, len(files), "the new splotch shouldn't be left behind")
+
+	//once it can be saved, it all goes.
+	if err := os.Remove(is.manifestLocation() + ".tmp"); err != nil {
+		t.Fatal(err)
+	}
+	if err := is.truncate(); err != nil {
+		t.Fatal(err)
+	}
+	is2, err := NewInkSack(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err = is2.GetAll(SplotchKey{}, MaxSplotchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, items)
+	assert.Equal(t, 1, len(is2.inkSplotches))
+}
@@ -0,0 +1,210 @@
+package inkdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// each tombstone in the log is its key, followed by the unix nano time it was tombstoned.
+const tombstoneSize = 16
+
+// how long a tombstoned record sticks around on the disc before Compact can drop it for good. Until then it's only hidden.
+// left at 0, the next Compact drops it. Compact doesn't touch archived splotches though, so a record in one stays
+// (hidden, along with its tombstone) however long ago it was tombstoned, until Unarchive brings it back.
+func WithTombstoneGrace(grace time.Duration) TableOption {
+	return func(md *sackMetadata) {
+		md.TombstoneGrace = grace
+	}
+}
+
+// deletes the record under key from the table. Nothing is rewritten, instead a tombstone is added that hides the record
+// from Get and Scan from now on (and saved on the next Commit). Compact drops it for good once its grace period is up.
+func (ink *InkDB) Tombstone(table string, key SplotchKey) error {
+	if err := ink.checkWritable(); err != nil {
+		return err
+	}
+	sack, err := ink.getSack(table)
+	if err != nil {
+		return err
+	}
+	if err := sack.lock(); err != nil {
+		return err
+	}
+	defer sack.mu.Unlock()
+	if err := sack.tombstone(key, time.Now()); err != nil {
+		return err
+	}
+	ink.flusher.added(1, 0)
+	return nil
+}
+
+func (is *inkSack) tombstone(key SplotchKey, now time.Time) error {
+	//a tombstone past the end would hide whatever gets that key later on.
+	if key.GreaterThan(is.largestKey) {
+		return fmt.Errorf("%w: nothing has been stored under %v yet", ErrSplotchRangeExceeded, key.Uint64())
+	}
+	if _, found := is.tombstones[key]; found {
+		return nil
+	}
+	if is.tombstones == nil {
+		is.tombstones = map[SplotchKey]int64{}
+	}
+	is.tombstones[key] = now.UnixNano()
+	is.unsavedTombstones = append(is.unsavedTombstones, key)
+	//whatever was worked out for the splotch holding it counted the record.
+	is.partials.forget()
+	return nil
+}
+
+// where the sack's tombstones are logged.
+func (is *inkSack) tombstonesLocation() string {
+	return path.Join(is.localFilesLocation, "tombstones")
+}
+
+// the size and modification time of a file, to tell if it has changed since it was last read.
+type logStamp struct {
+	read     bool  //if it has been read at all
+	size     int64 //-1 if there wasn't a file
+	modified time.Time
+}
+
+func (stamp logStamp) same(other logStamp) bool {
+	return stamp.read == other.read && stamp.size == other.size && stamp.modified.Equal(other.modified)
+}
+
+// reads every tombstone from the log. A tombstone only part way written when we last stopped is ignored.
+// if the log hasn't changed since it was last read, nothing is, so read only databases can call this on every read.
+func (is *inkSack) loadTombstones() error {
+	stamp := logStamp{read: true, size: -1}
+	info, err := os.Stat(is.tombstonesLocation())
+	if err == nil {
+		stamp.size, stamp.modified = info.Size(), info.ModTime()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if stamp.same(is.tombstonesRead) {
+		return nil
+	}
+	data, err := os.ReadFile(is.tombstonesLocation())
+	if errors.Is(err, os.ErrNotExist) {
+		is.tombstones = nil
+		is.tombstonesRead = stamp
+		is.partials.forget()
+		return nil
+	} else if err != nil {
+		return err
+	}
+	is.tombstones = make(map[SplotchKey]int64, len(data)/tombstoneSize)
+	for ; len(data) >= tombstoneSize; data = data[tombstoneSize:] {
+		is.tombstones[SplotchKey(data[:8])] = int64(binary.BigEndian.Uint64(data[8:16]))
+	}
+	is.unsavedTombstones = nil
+	is.tombstonesRead = stamp
+	//another process could have tombstoned anything since.
+	is.partials.forget()
+	return nil
+}
+
+// adds any new tombstones onto the end of the log.
+func (is *inkSack) saveTombstones() error {
+	if len(is.unsavedTombstones) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(is.tombstonesLocation(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, is.metadata.fileMode())
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(is.encodeTombstones(is.unsavedTombstones)); err != nil {
+		f.Close()
+		return err
+	}
+	if is.metadata.Durability == DurabilitySync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	is.unsavedTombstones = nil
+	return nil
+}
+
+// forgets every tombstone drop says to, rewriting the log without them. Any that haven't been saved yet get saved with it.
+func (is *inkSack) dropTombstones(drop func(key SplotchKey, at int64) bool) error {
+	dropped := 0
+	for key, at := range is.tombstones {
+		if drop(key, at) {
+			delete(is.tombstones, key)
+			dropped++
+		}
+	}
+	if dropped == 0 {
+		return nil
+	}
+	keys := make([]SplotchKey, 0, len(is.tombstones))
+	for key := range is.tombstones {
+		keys = append(keys, key)
+	}
+	//like the manifest, it goes to a temp file first so the log is never half rewritten.
+	tmpLocation := is.tombstonesLocation() + ".tmp"
+	if err := os.WriteFile(tmpLocation, is.encodeTombstones(keys), is.metadata.fileMode()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpLocation, is.tombstonesLocation()); err != nil {
+		return err
+	}
+	is.unsavedTombstones = nil
+	is.logger.Debug("dropped tombstones", zap.Int("tombstones", dropped))
+	return nil
+}
+
+func (is *inkSack) encodeTombstones(keys []SplotchKey) []byte {
+	data := make([]byte, 0, len(keys)*tombstoneSize)
+	for _, key := range keys {
+		data = append(data, key[:]...)
+		data = binary.BigEndian.AppendUint64(data, uint64(is.tombstones[key]))
+	}
+	return data
+}
+
+// items, without any that have been tombstoned.
+func (is *inkSack) hideTombstoned(items []storedItem) []storedItem {
+	if len(is.tombstones) == 0 {
+		return items
+	}
+	kept := items[:0]
+	for _, item := range items {
+		if _, found := is.tombstones[item.Key]; !found {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// every key that has been tombstoned for longer than the grace period, so can be dropped for good.
+func (is *inkSack) expiredTombstones(now time.Time) map[SplotchKey]bool {
+	expired := map[SplotchKey]bool{}
+	for key, at := range is.tombstones {
+		if now.Sub(time.Unix(0, at)) >= is.metadata.TombstoneGrace {
+			expired[key] = true
+		}
+	}
+	return expired
+}
+
+// copies the tombstone log into dest's folder, if there is one.
+func (is *inkSack) copyTombstones(dest string) error {
+	err := copyFile(is.tombstonesLocation(), path.Join(dest, "tombstones"), is.metadata.fileMode())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
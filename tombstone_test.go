@@ -0,0 +1,102 @@
+package inkdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInkDBTombstone(t *testing.T) {
+	folder := getInkTestFile()
+	ink, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rollover := WithRollover(RolloverPolicy{MaxRows: 2})
+	if err := ink.NewTable("table", []byte{}, WithCodec(rawCodec{}), rollover); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink.NewTable("graceful", []byte{}, WithCodec(rawCodec{}), rollover, WithTombstoneGrace(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := ink.Append("table", []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := ink.Append("graceful", []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []uint64{3, 4, 9} {
+		if err := ink.Tombstone("table", KeyFromUint64(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.ErrorIs(t, ink.Tombstone("table", KeyFromUint64(50)), ErrSplotchRangeExceeded)
+	if err := ink.Tombstone("graceful", KeyFromUint64(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []SplotchKey{KeyFromUint64(1), KeyFromUint64(2), KeyFromUint64(5), KeyFromUint64(6),
+		KeyFromUint64(7), KeyFromUint64(8), KeyFromUint64(10)}
+	checkKeys := func(ink *InkDB) {
+		_, keys, err := ink.Get("table", KeyFromUint64(0), KeyFromUint64(100))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expected, keys)
+		scanned := []SplotchKey{}
+		err = ink.Scan("table", KeyFromUint64(0), KeyFromUint64(100), func(key SplotchKey, value any) error {
+			scanned = append(scanned, key)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expected, scanned)
+	}
+	checkKeys(ink)
+	//a range with nothing but tombstones in it.
+	_, keys, _ := ink.Get("table", KeyFromUint64(3), KeyFromUint64(4))
+	assert.Empty(t, keys)
+	stats, _ := ink.Stats("table")
+	assert.Equal(t, 3, stats.Tombstones)
+	if err := ink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//they're kept with the table.
+	ink2, err := NewInkDB(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ink2.Close()
+	if err := ink2.NewTable("table", []byte{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ink2.NewTable("graceful", []byte{}); err != nil {
+		t.Fatal(err)
+	}
+	checkKeys(ink2)
+
+	//3 and 4 go with their splotch merged into the one before it. 9 is in the last splotch, so it's only hidden.
+	report, err := ink2.Compact("table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, CompactReport{SplotchesBefore: 5, SplotchesAfter: 4, Written: 1, RecordsDropped: 2}, report)
+	stats, _ = ink2.Stats("table")
+	assert.Equal(t, 1, stats.Tombstones)
+	assert.Equal(t, 8, stats.Records)
+	checkKeys(ink2)
+
+	//still in its grace period.
+	report, err = ink2.Compact("graceful")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, report.RecordsDropped)
+	_, keys, _ = ink2.Get("graceful", KeyFromUint64(0), KeyFromUint64(100))
+	assert.Len(t, keys, 9)
+}